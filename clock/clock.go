@@ -0,0 +1,61 @@
+package clock
+
+import (
+	"context"
+	"time"
+)
+
+// Clock is a source of time for the simulation. Goroutines whose progress
+// depends on the passage of time must be started with Go, so that a simulated
+// clock knows when every one of them is asleep and time can move forward
+type Clock interface {
+	// Now returns the current time
+	Now() time.Time
+	// Sleep pauses the calling goroutine for the given duration
+	Sleep(d time.Duration)
+	// SleepContext pauses the calling goroutine for the given duration, or
+	// until the context is done; returns the error of the context if so
+	SleepContext(ctx context.Context, d time.Duration) error
+	// Go runs f in a new goroutine
+	Go(f func())
+	// Idle runs f, which blocks until goroutines started with Go make progress
+	// (e.g. waiting on a sync.WaitGroup)
+	Idle(f func())
+}
+
+type realClock struct{}
+
+func (r *realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (r *realClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+func (r *realClock) SleepContext(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *realClock) Go(f func()) {
+	go f()
+}
+
+func (r *realClock) Idle(f func()) {
+	f()
+}
+
+// GetRealClock constructs a clock that follows the wall-clock time
+func GetRealClock() Clock {
+	return &realClock{}
+}
@@ -0,0 +1,42 @@
+package clock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ClockTestSuite struct {
+	suite.Suite
+}
+
+func (c *ClockTestSuite) TestRealClock() {
+	clk := GetRealClock()
+	start := clk.Now()
+	clk.Sleep(10 * time.Millisecond)
+	c.GreaterOrEqual(clk.Now().Sub(start).Milliseconds(), int64(10))
+	done := make(chan struct{})
+	clk.Go(func() {
+		close(done)
+	})
+	clk.Idle(func() {
+		<-done
+	})
+}
+
+func (c *ClockTestSuite) TestRealClockSleepContext() {
+	clk := GetRealClock()
+	c.NoError(clk.SleepContext(context.Background(), time.Millisecond))
+	ctx, cancel := context.WithCancel(context.Background())
+	start := clk.Now()
+	time.AfterFunc(10*time.Millisecond, cancel)
+	c.Equal(context.Canceled, clk.SleepContext(ctx, time.Hour))
+	c.Less(clk.Now().Sub(start).Seconds(), float64(1))
+	c.Equal(context.Canceled, clk.SleepContext(ctx, time.Hour)) // done already
+}
+
+func TestClockTestSuite(t *testing.T) {
+	suite.Run(t, new(ClockTestSuite))
+}
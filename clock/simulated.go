@@ -0,0 +1,184 @@
+package clock
+
+import (
+	"container/heap"
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// timer is a goroutine sleeping on the simulated clock
+type timer struct {
+	at       time.Time
+	sequence int
+	wake     chan struct{}
+	index    int // in the timer queue, or -1 once woken up
+}
+
+// timerQueue is a min-heap of timers ordered by wake-up time; timers that wake
+// up at the same time are ordered by the time they were set
+type timerQueue []*timer
+
+func (t timerQueue) Len() int {
+	return len(t)
+}
+
+func (t timerQueue) Less(i, j int) bool {
+	if t[i].at.Equal(t[j].at) {
+		return t[i].sequence < t[j].sequence
+	}
+	return t[i].at.Before(t[j].at)
+}
+
+func (t timerQueue) Swap(i, j int) {
+	t[i], t[j] = t[j], t[i]
+	t[i].index = i
+	t[j].index = j
+}
+
+func (t *timerQueue) Push(x interface{}) {
+	item := x.(*timer)
+	item.index = len(*t)
+	*t = append(*t, item)
+}
+
+func (t *timerQueue) Pop() interface{} {
+	old := *t
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	item.index = -1
+	*t = old[:n-1]
+	return item
+}
+
+// simulatedClock runs the goroutines started on it one at a time, in a
+// deterministic order. Time only moves forward when every goroutine is asleep,
+// and it jumps straight to the earliest wake-up time, so no real time is spent
+// sleeping.
+//
+// The goroutine that constructs the clock is the first one to run; it gives
+// way to the others whenever it calls Sleep or Idle.
+type simulatedClock struct {
+	mutex    *sync.Mutex
+	now      time.Time
+	sequence int
+	running  bool       // whether a goroutine is currently running
+	runQueue *list.List // goroutines ready to run (chan struct{})
+	timers   *timerQueue
+}
+
+func (s *simulatedClock) Now() time.Time {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.now
+}
+
+func (s *simulatedClock) Sleep(d time.Duration) {
+	s.SleepContext(context.Background(), d)
+}
+
+// SleepContext sleeps like Sleep, unless the context is done already. If the
+// context is done while sleeping, the goroutine wakes up early (at the time the
+// clock is at by then), once it is its turn to run
+func (s *simulatedClock) SleepContext(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	wake := make(chan struct{})
+	s.mutex.Lock()
+	s.sequence++
+	t := &timer{
+		at:       s.now.Add(d),
+		sequence: s.sequence,
+		wake:     wake,
+	}
+	heap.Push(s.timers, t)
+	s.next()
+	s.mutex.Unlock()
+	select {
+	case <-wake:
+		return nil
+	case <-ctx.Done():
+	}
+	s.mutex.Lock()
+	if t.index >= 0 { // still asleep; wake up as soon as the others let it
+		heap.Remove(s.timers, t.index)
+		s.runQueue.PushBack(wake)
+		if !s.running {
+			s.next()
+		}
+	}
+	s.mutex.Unlock()
+	<-wake
+	return ctx.Err()
+}
+
+func (s *simulatedClock) Go(f func()) {
+	wake := make(chan struct{})
+	s.mutex.Lock()
+	s.runQueue.PushBack(wake)
+	if !s.running {
+		s.next()
+	}
+	s.mutex.Unlock()
+	go func() {
+		<-wake
+		defer s.yield()
+		f()
+	}()
+}
+
+func (s *simulatedClock) Idle(f func()) {
+	s.yield()
+	f()
+	s.mutex.Lock()
+	if !s.running { // nothing else is running; carry on right away
+		s.running = true
+		s.mutex.Unlock()
+		return
+	}
+	wake := make(chan struct{})
+	s.runQueue.PushBack(wake)
+	s.mutex.Unlock()
+	<-wake
+}
+
+// yield <private> hands over to the next goroutine
+func (s *simulatedClock) yield() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.next()
+}
+
+// next <private> wakes up the next goroutine to run; goroutines that are ready
+// run before the time moves forward to the earliest sleeping goroutine.
+// Must be called with the mutex held
+func (s *simulatedClock) next() {
+	s.running = true
+	if s.runQueue.Len() > 0 {
+		close(s.runQueue.Remove(s.runQueue.Front()).(chan struct{}))
+		return
+	}
+	if s.timers.Len() > 0 {
+		t := heap.Pop(s.timers).(*timer)
+		if t.at.After(s.now) {
+			s.now = t.at
+		}
+		close(t.wake)
+		return
+	}
+	s.running = false
+}
+
+// GetSimulatedClock constructs a simulated clock starting at the given time
+func GetSimulatedClock(start time.Time) Clock {
+	return &simulatedClock{
+		mutex:    &sync.Mutex{},
+		now:      start,
+		running:  true,
+		runQueue: list.New(),
+		timers:   &timerQueue{},
+	}
+}
@@ -0,0 +1,96 @@
+package clock
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type SimulatedClockTestSuite struct {
+	suite.Suite
+	start time.Time
+}
+
+func (s *SimulatedClockTestSuite) SetupTest() {
+	s.start = time.Date(2022, time.May, 1, 12, 0, 0, 0, time.UTC)
+}
+
+func (s *SimulatedClockTestSuite) TestSleep() {
+	clk := GetSimulatedClock(s.start)
+	began := time.Now()
+	clk.Sleep(time.Hour)
+	s.Equal(s.start.Add(time.Hour), clk.Now())
+	s.Less(time.Since(began).Seconds(), float64(1))
+}
+
+func (s *SimulatedClockTestSuite) TestGoroutinesWakeUpInOrder() {
+	clk := GetSimulatedClock(s.start)
+	wg := &sync.WaitGroup{}
+	woken := []int{}
+	for _, seconds := range []int{5, 1, 3, 1} {
+		wg.Add(1)
+		seconds := seconds
+		clk.Go(func() {
+			defer wg.Done()
+			clk.Sleep(time.Duration(seconds) * time.Second)
+			woken = append(woken, seconds) // goroutines never run at the same time
+			s.Equal(s.start.Add(time.Duration(seconds)*time.Second), clk.Now())
+		})
+	}
+	clk.Idle(wg.Wait)
+	s.Equal([]int{1, 1, 3, 5}, woken)
+	s.Equal(s.start.Add(5*time.Second), clk.Now())
+}
+
+func (s *SimulatedClockTestSuite) TestIdleWithoutGoroutines() {
+	clk := GetSimulatedClock(s.start)
+	called := false
+	clk.Idle(func() {
+		called = true
+	})
+	s.True(called)
+	clk.Sleep(time.Second)
+	s.Equal(s.start.Add(time.Second), clk.Now())
+}
+
+func (s *SimulatedClockTestSuite) TestSleepContext() {
+	clk := GetSimulatedClock(s.start)
+	ctx, cancel := context.WithCancel(context.Background())
+	s.NoError(clk.SleepContext(ctx, time.Second))
+	s.Equal(s.start.Add(time.Second), clk.Now())
+
+	var err error
+	var wokenAt time.Time
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	clk.Go(func() {
+		defer wg.Done()
+		err = clk.SleepContext(ctx, time.Hour)
+		wokenAt = clk.Now()
+	})
+	clk.Sleep(0) // lets the goroutine fall asleep, without moving the time
+	cancel()
+	simulated := clk.(*simulatedClock)
+	for { // until the goroutine has noticed, while this one is still running
+		simulated.mutex.Lock()
+		woken := simulated.runQueue.Len() > 0
+		simulated.mutex.Unlock()
+		if woken {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	clk.Idle(wg.Wait)
+	s.Equal(context.Canceled, err)
+	s.Equal(s.start.Add(time.Second), wokenAt) // the hour never passed
+	s.Equal(s.start.Add(time.Second), clk.Now())
+	s.Equal(context.Canceled, clk.SleepContext(ctx, time.Hour)) // done already
+	s.Equal(s.start.Add(time.Second), clk.Now())
+}
+
+func TestSimulatedClockTestSuite(t *testing.T) {
+	suite.Run(t, new(SimulatedClockTestSuite))
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"wonsoh.private/cloudkitchens/generator"
+	"wonsoh.private/cloudkitchens/reader"
+)
+
+// errUsage is returned for arguments that cannot be parsed, once the flag set
+// has reported them along with the usage
+var errUsage = errors.New("invalid arguments")
+
+// generate generates the orders that the arguments ask for, and writes them to
+// the -out file, or to stdout; returns the number of orders written
+func generate(args []string, stdout io.Writer, stderr io.Writer) (int, error) {
+	flags := flag.NewFlagSet("generate", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	count := flags.Int("count", 100, "number of orders to generate")
+	seed := flags.Int64("seed", 0, "seed of the random number generator; the same flags and seed generate the same orders")
+	arrival := flags.String("arrival", string(generator.ArrivalPoisson), "arrival process of the orders. poisson for a Poisson process at -rate; replay to replay the scheduled times of -sample")
+	rate := flags.Float64("rate", generator.DefaultOrdersPerSecond, "average number of orders per second of the Poisson process")
+	prep := flags.String("prep", string(generator.DistributionUniform), "distribution of the prep times. uniform (-prep-min to -prep-max); normal or lognormal (-prep-mean and -prep-stddev); empirical (those of -sample)")
+	prepMin := flags.Int("prep-min", 3, "shortest prep time in seconds of the uniform distribution")
+	prepMax := flags.Int("prep-max", 15, "longest prep time in seconds of the uniform distribution")
+	prepMean := flags.Float64("prep-mean", 9, "mean prep time in seconds of the normal and log-normal distributions")
+	prepStdDev := flags.Float64("prep-stddev", 3, "standard deviation of the prep times in seconds of the normal and log-normal distributions")
+	sample := flags.String("sample", "", "file of sample orders (in any format the simulation reads) for -arrival replay and -prep empirical")
+	menu := flags.String("menu", "", "JSON file of the menu, as an array of {name, temp, shelfLife, decayRate}; or sample to use the dishes of -sample. The built-in menu by default")
+	out := flags.String("out", "-", "file to write the orders to; - for the standard output")
+	format := flags.String("format", "", "format of the orders: json, ndjson, or csv; by default, told by the extension of -out (json for the standard output)")
+	if err := flags.Parse(args); err == flag.ErrHelp {
+		return 0, err
+	} else if err != nil {
+		return 0, errUsage
+	}
+	if flags.NArg() > 0 {
+		return 0, fmt.Errorf("unexpected arguments %s", strings.Join(flags.Args(), " "))
+	}
+	if *menu == "sample" && *sample == "" {
+		return 0, errors.New("-menu sample needs the sample orders of -sample")
+	}
+
+	config := generator.Config{
+		Count: *count,
+		Arrival: generator.ArrivalConfig{
+			Process:         generator.ArrivalProcess(*arrival),
+			OrdersPerSecond: *rate,
+		},
+		PrepTime: generator.PrepTimeConfig{
+			Distribution: generator.Distribution(*prep),
+			Min:          *prepMin,
+			Max:          *prepMax,
+			Mean:         *prepMean,
+			StdDev:       *prepStdDev,
+		},
+		Seed: *seed,
+	}
+	if *sample != "" {
+		orders, err := reader.GetFileOrderReader(*sample).ReadOrders()
+		if err != nil {
+			return 0, fmt.Errorf("cannot read the sample orders from %s: %v", *sample, err)
+		}
+		config.Arrival.Times = generator.GetSampleArrivalTimes(orders)
+		config.PrepTime.Samples = generator.GetSamplePrepTimes(orders)
+		if *menu == "sample" {
+			config.Menu = generator.GetSampleMenu(orders)
+		}
+	}
+	if *menu != "" && *menu != "sample" {
+		bytes, err := os.ReadFile(*menu)
+		if err == nil {
+			err = json.Unmarshal(bytes, &config.Menu)
+		}
+		if err != nil {
+			return 0, fmt.Errorf("cannot read the menu from %s: %v", *menu, err)
+		}
+	}
+	orders, err := generator.GetGenerator(config)
+	if err != nil {
+		return 0, err
+	}
+
+	var orderFormat reader.Format
+	if *format != "" {
+		orderFormat, err = reader.ParseFormat(*format)
+	} else {
+		orderFormat, err = reader.GetFormat(*out)
+	}
+	if err != nil {
+		return 0, err
+	}
+	w := stdout
+	var file *os.File
+	if *out != reader.StdinPath {
+		if file, err = os.Create(*out); err != nil {
+			return 0, err
+		}
+		defer file.Close()
+		w = file
+	}
+	written, err := generator.WriteOrders(w, orderFormat, orders)
+	if err == nil && file != nil {
+		err = file.Close()
+	}
+	if err != nil {
+		return written, fmt.Errorf("cannot write the orders (%d written): %v", written, err)
+	}
+	return written, nil
+}
+
+func main() {
+	written, err := generate(os.Args[1:], os.Stdout, os.Stderr)
+	switch {
+	case err == flag.ErrHelp:
+		return
+	case err == errUsage:
+		os.Exit(2) // as the flag package does
+	case err != nil:
+		log.Fatal(err)
+	}
+	log.Printf("Generated %d order(s)", written)
+}
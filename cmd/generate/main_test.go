@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"wonsoh.private/cloudkitchens/reader"
+	"wonsoh.private/cloudkitchens/resource"
+)
+
+type GenerateTestSuite struct {
+	suite.Suite
+	dir string
+}
+
+func (g *GenerateTestSuite) SetupTest() {
+	g.dir = g.T().TempDir()
+}
+
+// generateOrders runs the command with the arguments, and reads the orders it
+// writes to the standard output in the format
+func (g *GenerateTestSuite) generateOrders(format reader.Format, args ...string) []*resource.Order {
+	var stdout, stderr bytes.Buffer
+	written, err := generate(args, &stdout, &stderr)
+	g.Require().NoError(err, args)
+	g.Empty(stderr.String(), args)
+	stream, err := reader.GetFormatOrderStream(&stdout, format)
+	g.Require().NoError(err)
+	var orders []*resource.Order
+	for {
+		order, err := stream.Next()
+		if err == io.EOF {
+			break
+		}
+		g.Require().NoError(err, args)
+		orders = append(orders, order)
+	}
+	g.Len(orders, written, args)
+	return orders
+}
+
+func (g *GenerateTestSuite) TestOutput() {
+	orders := g.generateOrders(reader.FormatJSON, "-count", "20", "-seed", "7")
+	g.Len(orders, 20)
+	g.Empty(reader.ValidateOrders(orders))
+	for _, order := range orders {
+		g.GreaterOrEqual(order.PrepTime, 3)
+		g.LessOrEqual(order.PrepTime, 15)
+	}
+	g.Equal(orders, g.generateOrders(reader.FormatJSON, "-count", "20", "-seed", "7", "-out", "-"))
+	g.Equal(orders, g.generateOrders(reader.FormatNDJSON, "-count", "20", "-seed", "7", "-format", "ndjson"))
+	g.NotEqual(orders, g.generateOrders(reader.FormatJSON, "-count", "20", "-seed", "8"))
+
+	// to a file, in the format of its extension
+	for _, name := range []string{"orders.csv", "orders.jsonl", "orders.json"} {
+		path := filepath.Join(g.dir, name)
+		var stdout bytes.Buffer
+		written, err := generate([]string{"-count", "20", "-seed", "7", "-out", path}, &stdout, &bytes.Buffer{})
+		g.NoError(err, name)
+		g.Equal(20, written, name)
+		g.Empty(stdout.String(), name)
+		read, err := reader.GetFileOrderReader(path).ReadOrders()
+		g.NoError(err, name)
+		g.Equal(orders, read, name)
+	}
+}
+
+func (g *GenerateTestSuite) TestSample() {
+	samplePath := filepath.Join("..", "..", "resource", "dispatch_orders.json")
+	sample, err := reader.GetFileOrderReader(samplePath).ReadOrders()
+	g.Require().NoError(err)
+	names := map[string]bool{}
+	prepTimes := map[int]bool{}
+	for _, order := range sample {
+		names[order.Name] = true
+		prepTimes[order.PrepTime] = true
+	}
+	for _, order := range g.generateOrders(reader.FormatJSON, "-count", "50", "-sample", samplePath, "-prep", "empirical", "-menu", "sample") {
+		g.True(names[order.Name], order.Name)
+		g.True(prepTimes[order.PrepTime], order.PrepTime)
+	}
+
+	menuPath := filepath.Join(g.dir, "menu.json")
+	g.Require().NoError(os.WriteFile(menuPath, []byte(`[{"name": "Tea", "temp": "hot", "shelfLife": 100, "decayRate": 0.5}]`), 0644))
+	for _, order := range g.generateOrders(reader.FormatJSON, "-count", "5", "-menu", menuPath) {
+		g.Equal("Tea", order.Name)
+		g.Equal(resource.TemperatureHot, order.Temp)
+	}
+}
+
+func (g *GenerateTestSuite) TestInvalidFlags() {
+	samplePath := filepath.Join("..", "..", "resource", "dispatch_orders.json")
+	badSamplePath := filepath.Join(g.dir, "bad_sample.json")
+	g.Require().NoError(os.WriteFile(badSamplePath, []byte(`[{"id": "1", "name": "Tea", "prepTime": 0}]`), 0644))
+	var badMenus []string
+	for i, menu := range []string{
+		`[{"name": "", "temp": "hot", "shelfLife": 100, "decayRate": 0.5}]`,
+		`[{"name": "Tea", "temp": "warm", "shelfLife": 100, "decayRate": 0.5}]`,
+		`[{"name": "Tea", "temp": "hot", "shelfLife": -1, "decayRate": 0.5}]`,
+		`[{"name": "Tea", "temp": "hot", "shelfLife": 100, "decayRate": -1}]`,
+	} {
+		path := filepath.Join(g.dir, fmt.Sprintf("bad_menu_%d.json", i))
+		g.Require().NoError(os.WriteFile(path, []byte(menu), 0644))
+		badMenus = append(badMenus, path)
+	}
+	for _, args := range [][]string{
+		{"-menu", badMenus[0]},
+		{"-menu", badMenus[1]},
+		{"-menu", badMenus[2]},
+		{"-menu", badMenus[3]},
+		{"-prep", "empirical", "-sample", badSamplePath},
+		{"-count", "-1"},
+		{"-arrival", "burst"},
+		{"-arrival", "replay"},                        // no sample
+		{"-arrival", "replay", "-sample", samplePath}, // whose times are all 0
+		{"-prep", "gamma"},
+		{"-prep-min", "6", "-prep-max", "5"},
+		{"-prep", "empirical"},
+		{"-menu", "sample"},
+		{"-menu", filepath.Join(g.dir, "missing.json")},
+		{"-sample", filepath.Join(g.dir, "missing.json")},
+		{"-format", "xml"},
+		{"-out", filepath.Join(g.dir, "orders.xml")},
+		{"-out", filepath.Join(g.dir, "missing", "orders.json")},
+		{"-count", "5", "orders.json"},
+	} {
+		var stdout bytes.Buffer
+		_, err := generate(args, &stdout, &bytes.Buffer{})
+		g.Error(err, args)
+		g.NotEqual(errUsage, err, args)
+		g.Empty(stdout.String(), args)
+	}
+
+	var stderr bytes.Buffer
+	_, err := generate([]string{"-count", "many"}, &bytes.Buffer{}, &stderr)
+	g.Equal(errUsage, err)
+	g.Contains(stderr.String(), "-count") // along with the usage
+	_, err = generate([]string{"-h"}, &bytes.Buffer{}, &bytes.Buffer{})
+	g.Equal(flag.ErrHelp, err)
+}
+
+func TestGenerateTestSuite(t *testing.T) {
+	suite.Run(t, new(GenerateTestSuite))
+}
@@ -0,0 +1,280 @@
+package generator
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"strings"
+
+	"github.com/google/uuid"
+	"wonsoh.private/cloudkitchens/resource"
+)
+
+// ArrivalProcess is how the arrival times of the orders are drawn
+type ArrivalProcess string
+
+const (
+	// ArrivalPoisson draws the time between two orders from an exponential
+	// distribution, so that the orders arrive as a Poisson process
+	ArrivalPoisson ArrivalProcess = "poisson"
+	// ArrivalReplay replays the times between the orders of a sample, over and
+	// over
+	ArrivalReplay ArrivalProcess = "replay"
+)
+
+// Distribution is the distribution the prep times are drawn from
+type Distribution string
+
+const (
+	// DistributionUniform draws the prep times uniformly between a minimum and
+	// a maximum
+	DistributionUniform Distribution = "uniform"
+	// DistributionNormal draws the prep times from a normal distribution
+	DistributionNormal Distribution = "normal"
+	// DistributionLogNormal draws the prep times from a log-normal
+	// distribution, skewed towards long prep times
+	DistributionLogNormal Distribution = "lognormal"
+	// DistributionEmpirical draws the prep times from those of a sample
+	DistributionEmpirical Distribution = "empirical"
+)
+
+// DefaultOrdersPerSecond is the default arrival rate of the Poisson process
+const DefaultOrdersPerSecond = 2
+
+// MenuItem is a dish that the generated orders are for
+type MenuItem struct {
+	Name      string  `json:"name"`
+	Temp      string  `json:"temp"`
+	ShelfLife int     `json:"shelfLife"`
+	DecayRate float64 `json:"decayRate"`
+}
+
+// DefaultMenu is the menu of the generated orders by default
+var DefaultMenu = []MenuItem{
+	{Name: "Banana Split", Temp: resource.TemperatureFrozen, ShelfLife: 20, DecayRate: 0.63},
+	{Name: "McFlury", Temp: resource.TemperatureFrozen, ShelfLife: 375, DecayRate: 0.4},
+	{Name: "Acai Bowl", Temp: resource.TemperatureCold, ShelfLife: 249, DecayRate: 0.3},
+	{Name: "Yogurt", Temp: resource.TemperatureCold, ShelfLife: 263, DecayRate: 0.37},
+	{Name: "Cobb Salad", Temp: resource.TemperatureCold, ShelfLife: 269, DecayRate: 0.19},
+	{Name: "Pad See Ew", Temp: resource.TemperatureHot, ShelfLife: 210, DecayRate: 0.72},
+	{Name: "Beef Stew", Temp: resource.TemperatureHot, ShelfLife: 257, DecayRate: 0.3},
+	{Name: "Orange Chicken", Temp: resource.TemperatureHot, ShelfLife: 240, DecayRate: 0.5},
+	{Name: "Hamburger", Temp: resource.TemperatureHot, ShelfLife: 300, DecayRate: 0.42},
+	{Name: "French Fries", Temp: resource.TemperatureHot, ShelfLife: 180, DecayRate: 0.9},
+}
+
+// ArrivalConfig configures the arrival times of the generated orders
+type ArrivalConfig struct {
+	// Process is how the arrival times are drawn; defaults to ArrivalPoisson
+	Process ArrivalProcess
+	// OrdersPerSecond is the average arrival rate of the Poisson process; zero
+	// (or less) means DefaultOrdersPerSecond
+	OrdersPerSecond float64
+	// Times are the arrival times in seconds of the sample to replay, in
+	// order (e.g. the scheduled times of its orders)
+	Times []float64
+}
+
+// PrepTimeConfig configures the prep times of the generated orders, which are
+// rounded to whole seconds, and at least a second
+type PrepTimeConfig struct {
+	// Distribution is the distribution the prep times are drawn from; defaults
+	// to DistributionUniform
+	Distribution Distribution
+	// Min and Max are the bounds of the uniform distribution in seconds; both
+	// zero means the 3 to 15 seconds of the sample orders
+	Min int
+	Max int
+	// Mean and StdDev are the mean and the standard deviation in seconds of
+	// the normal and log-normal distributions
+	Mean   float64
+	StdDev float64
+	// Samples are the prep times in seconds of the empirical distribution
+	Samples []int
+}
+
+// Config configures the generated orders
+type Config struct {
+	// Count is the number of orders to generate
+	Count    int
+	Arrival  ArrivalConfig
+	PrepTime PrepTimeConfig
+	// Menu is the menu the orders are drawn from uniformly; defaults to
+	// DefaultMenu
+	Menu []MenuItem
+	// Seed seeds the random number generator, so that the same configuration
+	// always generates the same orders
+	Seed int64
+}
+
+// Generator generates synthetic orders one at a time (it can feed the
+// dispatcher directly, as a service.OrderSource)
+type Generator interface {
+	// Next generates the next order; returns io.EOF once all the orders have
+	// been generated
+	Next() (*resource.Order, error)
+}
+
+type orderGenerator struct {
+	config    Config
+	random    *rand.Rand
+	generated int
+	time      float64 // arrival time in seconds of the last order
+}
+
+// getArrivalGap <private> draws the time in seconds from the last order to the
+// next one
+func (o *orderGenerator) getArrivalGap() float64 {
+	if o.config.Arrival.Process == ArrivalReplay {
+		times := o.config.Arrival.Times
+		i := o.generated % len(times)
+		if i == 0 {
+			return times[0]
+		}
+		return times[i] - times[i-1]
+	}
+	return o.random.ExpFloat64() / o.config.Arrival.OrdersPerSecond
+}
+
+// getPrepTime <private> draws the prep time of the next order
+func (o *orderGenerator) getPrepTime() int {
+	config := o.config.PrepTime
+	var seconds float64
+	switch config.Distribution {
+	case DistributionNormal:
+		seconds = o.random.NormFloat64()*config.StdDev + config.Mean
+	case DistributionLogNormal: // with the mean and the standard deviation of the prep times
+		sigma := math.Sqrt(math.Log(1 + config.StdDev*config.StdDev/(config.Mean*config.Mean)))
+		mu := math.Log(config.Mean) - sigma*sigma/2
+		seconds = math.Exp(mu + sigma*o.random.NormFloat64())
+	case DistributionEmpirical:
+		seconds = float64(config.Samples[o.random.Intn(len(config.Samples))])
+	default:
+		seconds = float64(config.Min + o.random.Intn(config.Max-config.Min+1))
+	}
+	return int(math.Max(1, math.Round(seconds)))
+}
+
+func (o *orderGenerator) Next() (*resource.Order, error) {
+	if o.generated >= o.config.Count {
+		return nil, io.EOF
+	}
+	id, err := uuid.NewRandomFromReader(o.random)
+	if err != nil {
+		return nil, err
+	}
+	o.time += o.getArrivalGap()
+	item := o.config.Menu[o.random.Intn(len(o.config.Menu))]
+	o.generated++
+	return &resource.Order{
+		ID:            id.String(),
+		Name:          item.Name,
+		PrepTime:      o.getPrepTime(),
+		Temp:          item.Temp,
+		ShelfLife:     item.ShelfLife,
+		DecayRate:     item.DecayRate,
+		ScheduledTime: math.Round(o.time*1000) / 1000, // to the millisecond
+	}, nil
+}
+
+// checkConfig <private> fills in the defaults of the configuration, and returns
+// why it cannot generate orders, if it cannot
+func checkConfig(config *Config) error {
+	if config.Count < 0 {
+		return fmt.Errorf("number of orders %d is negative", config.Count)
+	}
+	if len(config.Menu) == 0 {
+		config.Menu = DefaultMenu
+	}
+	for i, item := range config.Menu {
+		if err := checkMenuItem(item); err != nil {
+			return fmt.Errorf("menu item #%d (%q): %v", i+1, item.Name, err)
+		}
+	}
+	switch config.Arrival.Process {
+	case "", ArrivalPoisson:
+		config.Arrival.Process = ArrivalPoisson
+		if math.IsNaN(config.Arrival.OrdersPerSecond) {
+			return fmt.Errorf("orders per second is NaN")
+		}
+		if config.Arrival.OrdersPerSecond <= 0 {
+			config.Arrival.OrdersPerSecond = DefaultOrdersPerSecond
+		}
+	case ArrivalReplay:
+		if len(config.Arrival.Times) == 0 {
+			return fmt.Errorf("no arrival times to replay")
+		}
+		for i, time := range config.Arrival.Times {
+			if math.IsNaN(time) || time < 0 || (i > 0 && time < config.Arrival.Times[i-1]) {
+				return fmt.Errorf("arrival times to replay must be increasing from 0; got %v after %d time(s)", time, i)
+			}
+		}
+		if config.Arrival.Times[len(config.Arrival.Times)-1] <= 0 {
+			return fmt.Errorf("arrival times to replay are all 0")
+		}
+	default:
+		return fmt.Errorf("unknown arrival process %q; expected poisson or replay", config.Arrival.Process)
+	}
+	prepTime := &config.PrepTime
+	switch prepTime.Distribution {
+	case "", DistributionUniform:
+		prepTime.Distribution = DistributionUniform
+		if prepTime.Min == 0 && prepTime.Max == 0 {
+			prepTime.Min, prepTime.Max = 3, 15
+		}
+		if prepTime.Min < 1 || prepTime.Max < prepTime.Min {
+			return fmt.Errorf("uniform prep times must be from at least 1 second to no less; got %d to %d seconds", prepTime.Min, prepTime.Max)
+		}
+	case DistributionNormal, DistributionLogNormal:
+		if math.IsNaN(prepTime.Mean) || math.IsNaN(prepTime.StdDev) || prepTime.Mean <= 0 || prepTime.StdDev < 0 {
+			return fmt.Errorf("prep times must have a positive mean and a standard deviation of at least 0; got %v and %v", prepTime.Mean, prepTime.StdDev)
+		}
+	case DistributionEmpirical:
+		if len(prepTime.Samples) == 0 {
+			return fmt.Errorf("no prep times to sample")
+		}
+		for i, sample := range prepTime.Samples {
+			if sample <= 0 {
+				return fmt.Errorf("prep times to sample must be positive; got %d after %d time(s)", sample, i)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown prep time distribution %q; expected uniform, normal, lognormal, or empirical", prepTime.Distribution)
+	}
+	return nil
+}
+
+// checkMenuItem <private> returns why the dish cannot be ordered, if it cannot.
+// As for an order, a dish without a temperature is kept on the overflow shelf
+// only, and one without a shelf life never goes stale (as the dishes of the
+// sample orders, which have neither)
+func checkMenuItem(item MenuItem) error {
+	if strings.TrimSpace(item.Name) == "" {
+		return fmt.Errorf("empty name")
+	}
+	switch item.Temp {
+	case "", resource.TemperatureHot, resource.TemperatureCold, resource.TemperatureFrozen:
+	default:
+		return fmt.Errorf("unknown temperature %q; expected hot, cold, or frozen", item.Temp)
+	}
+	if item.ShelfLife < 0 {
+		return fmt.Errorf("shelf life %d is negative", item.ShelfLife)
+	}
+	if math.IsNaN(item.DecayRate) || item.DecayRate < 0 {
+		return fmt.Errorf("decay rate %v is not a number of at least 0", item.DecayRate)
+	}
+	return nil
+}
+
+// GetGenerator constructs a new Generator instance that generates the orders
+// of the configuration; returns an error if the configuration is invalid
+func GetGenerator(config Config) (Generator, error) {
+	if err := checkConfig(&config); err != nil {
+		return nil, err
+	}
+	return &orderGenerator{
+		config: config,
+		random: rand.New(rand.NewSource(config.Seed)),
+	}, nil
+}
@@ -0,0 +1,195 @@
+package generator
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"wonsoh.private/cloudkitchens/reader"
+	"wonsoh.private/cloudkitchens/resource"
+)
+
+type GeneratorTestSuite struct {
+	suite.Suite
+}
+
+// generate generates all the orders of the configuration
+func (g *GeneratorTestSuite) generate(config Config) []*resource.Order {
+	generator, err := GetGenerator(config)
+	g.Require().NoError(err)
+	var orders []*resource.Order
+	for {
+		order, err := generator.Next()
+		if err == io.EOF {
+			return orders
+		}
+		g.Require().NoError(err)
+		orders = append(orders, order)
+	}
+}
+
+func (g *GeneratorTestSuite) TestSeed() {
+	orders := g.generate(Config{Count: 50, Seed: 7})
+	g.Len(orders, 50)
+	g.Equal(orders, g.generate(Config{Count: 50, Seed: 7}))
+	g.NotEqual(orders, g.generate(Config{Count: 50, Seed: 8}))
+
+	// every order is valid, and the arrival times increase
+	g.Empty(reader.ValidateOrders(orders))
+	for i, order := range orders {
+		g.Contains(DefaultMenu, MenuItem{Name: order.Name, Temp: order.Temp, ShelfLife: order.ShelfLife, DecayRate: order.DecayRate})
+		if i > 0 {
+			g.GreaterOrEqual(order.ScheduledTime, orders[i-1].ScheduledTime)
+		}
+	}
+	g.Empty(g.generate(Config{}))
+}
+
+func (g *GeneratorTestSuite) TestPoisson() {
+	orders := g.generate(Config{Count: 2000, Arrival: ArrivalConfig{OrdersPerSecond: 4}})
+	// 2000 orders at 4 per second arrive in about 500 seconds
+	g.InDelta(500, orders[len(orders)-1].ScheduledTime, 50)
+}
+
+func (g *GeneratorTestSuite) TestReplay() {
+	orders := g.generate(Config{
+		Count:   5,
+		Arrival: ArrivalConfig{Process: ArrivalReplay, Times: []float64{0.5, 1, 3}},
+	})
+	var times []float64
+	for _, order := range orders {
+		times = append(times, order.ScheduledTime)
+	}
+	g.Equal([]float64{0.5, 1, 3, 3.5, 4}, times) // replayed over and over
+}
+
+func (g *GeneratorTestSuite) TestPrepTime() {
+	mean := func(config PrepTimeConfig) float64 {
+		sum := 0
+		orders := g.generate(Config{Count: 5000, PrepTime: config})
+		for _, order := range orders {
+			g.GreaterOrEqual(order.PrepTime, 1)
+			sum += order.PrepTime
+		}
+		return float64(sum) / float64(len(orders))
+	}
+	g.InDelta(9, mean(PrepTimeConfig{}), 0.5) // 3 to 15 seconds
+	g.InDelta(20, mean(PrepTimeConfig{Min: 10, Max: 30}), 0.5)
+	g.InDelta(20, mean(PrepTimeConfig{Distribution: DistributionNormal, Mean: 20, StdDev: 5}), 0.5)
+	g.InDelta(20, mean(PrepTimeConfig{Distribution: DistributionLogNormal, Mean: 20, StdDev: 10}), 0.5)
+	g.InDelta(5, mean(PrepTimeConfig{Distribution: DistributionEmpirical, Samples: []int{2, 8}}), 0.5)
+
+	for _, order := range g.generate(Config{Count: 100, PrepTime: PrepTimeConfig{Min: 4, Max: 6}}) {
+		g.GreaterOrEqual(order.PrepTime, 4)
+		g.LessOrEqual(order.PrepTime, 6)
+	}
+	for _, order := range g.generate(Config{Count: 100, PrepTime: PrepTimeConfig{Distribution: DistributionEmpirical, Samples: []int{2, 8}}}) {
+		g.Contains([]int{2, 8}, order.PrepTime)
+	}
+}
+
+func (g *GeneratorTestSuite) TestSample() {
+	sample := []*resource.Order{
+		{ID: "1", Name: "Yogurt", PrepTime: 4, Temp: resource.TemperatureCold, ShelfLife: 263, DecayRate: 0.37, ScheduledTime: 0},
+		{ID: "2", Name: "Hamburger", PrepTime: 7, Temp: resource.TemperatureHot, ShelfLife: 300, DecayRate: 0.42, ScheduledTime: 1.5},
+		{ID: "3", Name: "Yogurt", PrepTime: 5, Temp: resource.TemperatureCold, ShelfLife: 263, DecayRate: 0.37, ScheduledTime: 2},
+	}
+	g.Equal([]float64{0, 1.5, 2}, GetSampleArrivalTimes(sample))
+	g.Equal([]int{4, 7, 5}, GetSamplePrepTimes(sample))
+	menu := GetSampleMenu(sample)
+	g.Equal([]MenuItem{
+		{Name: "Yogurt", Temp: resource.TemperatureCold, ShelfLife: 263, DecayRate: 0.37},
+		{Name: "Hamburger", Temp: resource.TemperatureHot, ShelfLife: 300, DecayRate: 0.42},
+	}, menu)
+
+	for _, order := range g.generate(Config{Count: 20, Menu: menu}) {
+		g.Contains([]string{"Yogurt", "Hamburger"}, order.Name)
+	}
+}
+
+func (g *GeneratorTestSuite) TestConfigErrors() {
+	for _, config := range []Config{
+		{Count: -1},
+		{Arrival: ArrivalConfig{Process: "burst"}},
+		{Arrival: ArrivalConfig{Process: ArrivalReplay}},
+		{Arrival: ArrivalConfig{Process: ArrivalReplay, Times: []float64{0, 0}}},
+		{Arrival: ArrivalConfig{Process: ArrivalReplay, Times: []float64{1, 0.5}}},
+		{PrepTime: PrepTimeConfig{Distribution: "gamma"}},
+		{PrepTime: PrepTimeConfig{Min: 0, Max: 5}},
+		{PrepTime: PrepTimeConfig{Min: 6, Max: 5}},
+		{PrepTime: PrepTimeConfig{Distribution: DistributionNormal, StdDev: 2}},
+		{PrepTime: PrepTimeConfig{Distribution: DistributionLogNormal, Mean: 10, StdDev: -1}},
+		{PrepTime: PrepTimeConfig{Distribution: DistributionEmpirical}},
+		{PrepTime: PrepTimeConfig{Distribution: DistributionEmpirical, Samples: []int{3, 0}}},
+		{PrepTime: PrepTimeConfig{Distribution: DistributionNormal, Mean: math.NaN(), StdDev: 2}},
+		{Arrival: ArrivalConfig{OrdersPerSecond: math.NaN()}},
+		{Arrival: ArrivalConfig{Process: ArrivalReplay, Times: []float64{0, math.NaN(), 1}}},
+		{Menu: []MenuItem{{Name: " ", Temp: resource.TemperatureHot, ShelfLife: 100}}},
+		{Menu: []MenuItem{{Name: "Tea", Temp: "warm", ShelfLife: 100}}},
+		{Menu: []MenuItem{{Name: "Tea", Temp: resource.TemperatureHot, ShelfLife: -1}}},
+		{Menu: []MenuItem{{Name: "Tea", Temp: resource.TemperatureHot, ShelfLife: 100, DecayRate: -1}}},
+	} {
+		_, err := GetGenerator(config)
+		g.Error(err, "%+v", config)
+	}
+}
+
+func (g *GeneratorTestSuite) TestWriteOrders() {
+	config := Config{Count: 20, Seed: 3, PrepTime: PrepTimeConfig{Distribution: DistributionLogNormal, Mean: 9, StdDev: 4}}
+	orders := g.generate(config)
+	for _, format := range []reader.Format{reader.FormatJSON, reader.FormatNDJSON, reader.FormatCSV} {
+		generator, err := GetGenerator(config)
+		g.Require().NoError(err)
+		var buffer bytes.Buffer
+		count, err := WriteOrders(&buffer, format, generator)
+		g.NoError(err)
+		g.Equal(20, count)
+
+		stream, err := reader.GetFormatOrderStream(&buffer, format)
+		g.Require().NoError(err)
+		var read []*resource.Order
+		for {
+			order, err := stream.Next()
+			if err == io.EOF {
+				break
+			}
+			g.Require().NoError(err, format)
+			read = append(read, order)
+		}
+		g.Equal(orders, read, format)
+	}
+
+	var buffer bytes.Buffer
+	generator, _ := GetGenerator(Config{})
+	_, err := WriteOrders(&buffer, reader.FormatJSON, generator)
+	g.NoError(err)
+	stream, _ := reader.GetFormatOrderStream(&buffer, reader.FormatJSON)
+	_, err = stream.Next()
+	g.Equal(io.EOF, err) // an empty array
+
+	_, err = WriteOrders(&buffer, "xml", generator)
+	g.Error(err)
+
+	for _, format := range []reader.Format{reader.FormatJSON, reader.FormatNDJSON, reader.FormatCSV} {
+		generator, _ := GetGenerator(Config{Count: 1000})
+		count, err := WriteOrders(failingWriter{}, format, generator)
+		g.Equal(errFailingWriter, err, format)
+		g.Less(count, 1000, format) // stopped at the first write to fail
+	}
+}
+
+var errFailingWriter = errors.New("no space left")
+
+// failingWriter is a writer that fails to write anything
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errFailingWriter
+}
+
+func TestGeneratorTestSuite(t *testing.T) {
+	suite.Run(t, new(GeneratorTestSuite))
+}
@@ -0,0 +1,159 @@
+package generator
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"wonsoh.private/cloudkitchens/reader"
+	"wonsoh.private/cloudkitchens/resource"
+)
+
+// csvHeader is the header row of the CSV format, with the column names that
+// the reader expects
+var csvHeader = []string{
+	"id",
+	"name",
+	"prepTime",
+	"temp",
+	"shelfLife",
+	"decayRate",
+	"customerX",
+	"customerY",
+	"scheduledTime",
+}
+
+// formatFloat formats a number as briefly as possible
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// getCSVRow gets the row of an order; the cells of the fields that are not set
+// are empty
+func getCSVRow(order *resource.Order) []string {
+	row := []string{order.ID, order.Name, strconv.Itoa(order.PrepTime), order.Temp, "", "", "", "", ""}
+	if order.ShelfLife != 0 {
+		row[4] = strconv.Itoa(order.ShelfLife)
+	}
+	if order.DecayRate != 0 {
+		row[5] = formatFloat(order.DecayRate)
+	}
+	if order.Customer != nil {
+		row[6], row[7] = formatFloat(order.Customer.X), formatFloat(order.Customer.Y)
+	}
+	if order.ScheduledTime != 0 {
+		row[8] = formatFloat(order.ScheduledTime)
+	}
+	return row
+}
+
+// orderWriter writes the orders one at a time in a format
+type orderWriter interface {
+	write(order *resource.Order) error
+	// end finishes the output once all the orders are written
+	end() error
+}
+
+// jsonOrderWriter writes the orders as a JSON array, an order per line
+type jsonOrderWriter struct {
+	w       *bufio.Writer
+	written bool
+}
+
+// ndjsonOrderWriter writes the orders as newline-delimited JSON
+type ndjsonOrderWriter struct {
+	encoder *json.Encoder
+}
+
+// csvOrderWriter writes the orders as CSV, with a header row
+type csvOrderWriter struct {
+	w *csv.Writer
+}
+
+func (j *jsonOrderWriter) write(order *resource.Order) error {
+	bytes, err := json.Marshal(order)
+	if err != nil {
+		return err
+	}
+	separator := ",\n  "
+	if !j.written {
+		separator = "[\n  "
+		j.written = true
+	}
+	if _, err := j.w.WriteString(separator); err != nil {
+		return err
+	}
+	_, err = j.w.Write(bytes)
+	return err
+}
+
+func (j *jsonOrderWriter) end() error {
+	if !j.written { // an empty array
+		_, err := j.w.WriteString("[]\n")
+		return err
+	}
+	_, err := j.w.WriteString("\n]\n")
+	return err
+}
+
+func (n *ndjsonOrderWriter) write(order *resource.Order) error {
+	return n.encoder.Encode(order)
+}
+
+func (n *ndjsonOrderWriter) end() error {
+	return nil
+}
+
+func (c *csvOrderWriter) write(order *resource.Order) error {
+	return c.w.Write(getCSVRow(order))
+}
+
+func (c *csvOrderWriter) end() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// getOrderWriter <private> gets the writer of the orders to w in the format
+func getOrderWriter(w *bufio.Writer, format reader.Format) (orderWriter, error) {
+	switch format {
+	case reader.FormatJSON:
+		return &jsonOrderWriter{w: w}, nil
+	case reader.FormatNDJSON:
+		return &ndjsonOrderWriter{encoder: json.NewEncoder(w)}, nil
+	case reader.FormatCSV:
+		csvWriter := csv.NewWriter(w)
+		return &csvOrderWriter{w: csvWriter}, csvWriter.Write(csvHeader)
+	}
+	return nil, fmt.Errorf("unknown order format %q", format)
+}
+
+// WriteOrders writes the orders of the generator to w as they are generated,
+// in a format that the reader reads; returns the number of orders written
+func WriteOrders(w io.Writer, format reader.Format, generator Generator) (int, error) {
+	buffered := bufio.NewWriter(w)
+	writer, err := getOrderWriter(buffered, format)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for {
+		order, err := generator.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return count, err
+		}
+		if err := writer.write(order); err != nil {
+			return count, err
+		}
+		count++
+	}
+	if err := writer.end(); err != nil {
+		return count, err
+	}
+	return count, buffered.Flush()
+}
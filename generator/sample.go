@@ -0,0 +1,43 @@
+package generator
+
+import "wonsoh.private/cloudkitchens/resource"
+
+// GetSampleArrivalTimes gets the scheduled times of the sample orders, in
+// order, to replay with ArrivalReplay
+func GetSampleArrivalTimes(orders []*resource.Order) []float64 {
+	times := make([]float64, 0, len(orders))
+	for _, order := range orders {
+		times = append(times, order.ScheduledTime)
+	}
+	return times
+}
+
+// GetSamplePrepTimes gets the prep times of the sample orders, to draw from
+// with DistributionEmpirical
+func GetSamplePrepTimes(orders []*resource.Order) []int {
+	prepTimes := make([]int, 0, len(orders))
+	for _, order := range orders {
+		prepTimes = append(prepTimes, order.PrepTime)
+	}
+	return prepTimes
+}
+
+// GetSampleMenu gets the dishes of the sample orders, once each (as the first
+// order for it has them), in the order they first appear
+func GetSampleMenu(orders []*resource.Order) []MenuItem {
+	var menu []MenuItem
+	seen := map[string]bool{}
+	for _, order := range orders {
+		if seen[order.Name] {
+			continue
+		}
+		seen[order.Name] = true
+		menu = append(menu, MenuItem{
+			Name:      order.Name,
+			Temp:      order.Temp,
+			ShelfLife: order.ShelfLife,
+			DecayRate: order.DecayRate,
+		})
+	}
+	return menu
+}
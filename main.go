@@ -1,38 +1,188 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"time"
 
+	"wonsoh.private/cloudkitchens/clock"
 	"wonsoh.private/cloudkitchens/reader"
 	"wonsoh.private/cloudkitchens/resource"
 	"wonsoh.private/cloudkitchens/service"
+	"wonsoh.private/cloudkitchens/writer"
 )
 
 func main() {
-	strategy := flag.Int("s", 0, "strategy value to use. 0 for matched; 1 for FIFO. [default is 0--matched]")
+	strategyValue := flag.Int("s", 0, "strategy value to use. 0 for matched; 1 for FIFO. [default is 0--matched]")
+	strategyName := flag.String("strategy", "", fmt.Sprintf("name of the strategy to use, one of %v; overrides -s", service.GetStrategyNames()))
+	batchWindow := flag.Duration("batch-window", service.DefaultBatchWindow, "time between two batches of the batch strategy")
+	virtual := flag.Bool("virtual", false, "run the simulation on a virtual clock instead of in real time")
+	event := flag.Bool("event", false, "run the simulation on the single-threaded discrete-event engine")
+	rate := flag.Float64("rate", service.DefaultOrdersPerSecond, "number of orders dispatched per second; 0 dispatches all the orders at once")
+	jitter := flag.Float64("jitter", 0, "randomly stretches or shrinks each interval between dispatches by up to this fraction (0-1)")
+	burst := flag.Int("burst", 1, "number of orders dispatched together at every interval")
+	hotCapacity := flag.Int("hot", 0, "capacity of the hot shelf; 0 for unlimited")
+	coldCapacity := flag.Int("cold", 0, "capacity of the cold shelf; 0 for unlimited")
+	frozenCapacity := flag.Int("frozen", 0, "capacity of the frozen shelf; 0 for unlimited")
+	overflowCapacity := flag.Int("overflow", 0, "capacity of the overflow shelf; 0 for unlimited")
+	discard := flag.String("discard", string(service.DiscardOldest), "order to discard when the overflow shelf is full. oldest, newest, or random")
+	cookStations := flag.Int("stations", 0, "number of cook stations that cook food of any temperature; 0 for unlimited")
+	hotStations := flag.Int("hot-stations", 0, "number of cook stations dedicated to hot food")
+	coldStations := flag.Int("cold-stations", 0, "number of cook stations dedicated to cold food")
+	frozenStations := flag.Int("frozen-stations", 0, "number of cook stations dedicated to frozen food")
+	fleetSize := flag.Int("fleet", 0, "number of couriers, reused after each delivery; 0 for a new courier per order")
+	noShow := flag.Float64("no-show", 0, "probability that a courier never shows up at the kitchen and has to be replaced (0-1, exclusive of 1)")
+	noShowTimeout := flag.Int("no-show-timeout", 0, fmt.Sprintf("seconds after being dispatched that a courier who has not arrived is replaced; 0 for %d (plus the longest travel time with -radius)", service.DefaultNoShowTimeout))
+	stackSize := flag.Int("stack", 1, "most orders a courier picks up in a single visit to the kitchen when several are waiting (with FIFO or nearest)")
+	stackWait := flag.Int("stack-wait", 0, "longest time in seconds a courier holding fewer than -stack orders waits at the kitchen for more; 0 to leave with the ones ready")
+	radius := flag.Float64("radius", 0, "meters from the kitchen that the customers and couriers are placed within, for travel times by distance; 0 for random travel times")
+	speed := flag.Float64("speed", service.DefaultCourierSpeed, "speed of the couriers in meters per second (with -radius)")
+	ordersPath := flag.String("orders", reader.DefaultOrdersPath, fmt.Sprintf("file to read the orders from (see -format); %s for the standard input", reader.StdinPath))
+	ordersFormat := flag.String("format", "", "format of the orders: json (an array or NDJSON), ndjson, or csv; by default, told by the extension of -orders, or by its content if it has none (e.g. the standard input)")
+	validation := flag.String("validate", string(reader.ValidationStrict), "what to do with invalid or malformed orders, which are found as they are read. strict stops dispatching at the first one and reports only that one, while the orders read before it still run; lenient skips every one and reports it")
+	uniqueIDs := flag.Bool("unique-ids", false, "also treat an order with the ID of any earlier one of the input as invalid, which keeps the ID of every order in memory; otherwise only the ID of an undelivered order is rejected, which stops dispatching")
+	logFormat := flag.String("log", string(service.EventLogText), "format of the event log on the standard error. text for human-readable lines; json for NDJSON")
+	out := flag.String("out", "", "file to write the record of every picked up order to, as CSV (.csv) or JSON (.json)")
 	flag.Parse()
-	reader := reader.GetOrderReader()
-	orders, _ := reader.ReadOrders()
+	if *out != "" {
+		if _, err := writer.GetFormat(*out); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *jitter < 0 || *jitter > 1 {
+		log.Fatalf("jitter must be between 0 and 1; got %v", *jitter)
+	}
+	if *noShow < 0 || *noShow >= 1 {
+		log.Fatalf("no-show probability must be at least 0 and less than 1; got %v", *noShow)
+	}
+	discardPolicy, err := service.ParseDiscardPolicy(*discard)
+	if err != nil {
+		log.Fatal(err)
+	}
+	eventLogFormat, err := service.ParseEventLogFormat(*logFormat)
+	if err != nil {
+		log.Fatal(err)
+	}
+	validationMode, err := reader.ParseValidationMode(*validation)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var format reader.Format
+	if *ordersFormat != "" {
+		if format, err = reader.ParseFormat(*ordersFormat); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *strategyName == "" {
+		*strategyName = service.MatchedStrategyName
+		if *strategyValue == 1 {
+			*strategyName = service.FIFOStrategyName
+		}
+	}
+	strategy, err := service.GetStrategy(*strategyName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *strategyName == service.BatchStrategyName {
+		strategy = service.NewBatchStrategy(*batchWindow)
+	}
+	config := service.Config{
+		Shelves: service.ShelfConfig{
+			HotCapacity:      *hotCapacity,
+			ColdCapacity:     *coldCapacity,
+			FrozenCapacity:   *frozenCapacity,
+			OverflowCapacity: *overflowCapacity,
+			DiscardPolicy:    discardPolicy,
+		},
+		Kitchen: service.KitchenConfig{
+			CookStations: *cookStations,
+			SpecializedCookStations: map[string]int{
+				resource.TemperatureHot:    *hotStations,
+				resource.TemperatureCold:   *coldStations,
+				resource.TemperatureFrozen: *frozenStations,
+			},
+		},
+		Fleet: service.FleetConfig{
+			Size:              *fleetSize,
+			NoShowProbability: *noShow,
+			NoShowTimeout:     *noShowTimeout,
+			StackSize:         *stackSize,
+			StackWait:         *stackWait,
+		},
+		Geo: service.GeoConfig{
+			Radius: *radius,
+			Speed:  *speed,
+		},
+		Logger:      service.NewEventLogger(os.Stderr, eventLogFormat),
+		KeepRecords: *out != "", // only to write them out
+	}
+	stream, err := reader.GetFileOrderStream(*ordersPath, format) // decoded as they are dispatched
+	if err != nil {
+		log.Fatalf("cannot read the orders from %s: %v", *ordersPath, err)
+	}
+	defer stream.Close()
+	getValidatingStream := reader.GetValidatingStream
+	if *uniqueIDs {
+		getValidatingStream = reader.GetUniqueValidatingStream
+	}
+	orders := getValidatingStream(stream, validationMode, func(err *reader.ValidationError) {
+		log.Printf("[WARN] Skipped %v", err)
+	})
 	random := resource.GetFixedSeedRandomNumberGenerator()
-	var manager service.OrderManager
-	switch *strategy {
-	case 1:
-		manager = service.GetFIFOOrderManager(
-			random,
-		)
-	default:
-		manager = service.GetMatchedOrderManager(
-			random,
-		)
-	}
-	for _, order := range orders {
-		if e := manager.DispatchOrder(order); e != nil {
-			log.Panic(e)
+	clk := clock.GetRealClock()
+	if *virtual {
+		clk = clock.GetSimulatedClock(time.Now())
+	}
+	newOrderManager := service.NewOrderManager
+	if *event {
+		newOrderManager = service.NewEventOrderManager
+	}
+	manager := newOrderManager(
+		random,
+		clk,
+		config,
+		strategy,
+	)
+	dispatcher := service.GetDispatcher(
+		manager,
+		clk,
+		resource.GetFixedSeedRandomNumberGenerator(), // for the jitter only
+		service.DispatchRate{
+			OrdersPerSecond: *rate,
+			Jitter:          *jitter,
+			Burst:           *burst,
+		},
+	)
+	// on SIGINT, the simulation stops and reports the orders finished so far
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	dispatchErr := dispatcher.DispatchStream(ctx, orders)
+	if dispatchErr != nil && ctx.Err() == nil {
+		log.Printf("[ERROR] Stopped dispatching orders (msg: %v)", dispatchErr)
+	}
+	if skipped := orders.GetSkippedCount(); skipped > 0 {
+		log.Printf("[WARN] Skipped %d invalid order(s)", skipped)
+	}
+	e := manager.Wait(ctx)
+	if e != nil {
+		log.Printf("[ERROR] Simulation stopped before all orders were delivered (msg: %v)", e)
+		for _, order := range manager.GetUndeliveredOrders() {
+			log.Printf("[ERROR] Undelivered order ID %s (%s)", order.ID, order.Name)
 		}
 	}
-	manager.Wait()
 	manager.ReportStatistics()
+	if *out != "" {
+		if e := writer.WriteRecordsFile(*out, manager.GetRecords()); e != nil {
+			log.Panic(e)
+		}
+	}
+	if e != nil || dispatchErr != nil {
+		fmt.Println("STOPPED")
+		return
+	}
 	fmt.Println("DONE") // this line should appear after all orders have been processed
 }
@@ -0,0 +1,151 @@
+package reader
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"wonsoh.private/cloudkitchens/resource"
+)
+
+// csvColumns are the columns of the CSV format, by their normalized names (see
+// normalizeColumn); any other column is ignored
+var csvColumns = map[string]func(order *resource.Order, value string) error{
+	"id": func(order *resource.Order, value string) error {
+		order.ID = value
+		return nil
+	},
+	"name": func(order *resource.Order, value string) error {
+		order.Name = value
+		return nil
+	},
+	"preptime": func(order *resource.Order, value string) (err error) {
+		order.PrepTime, err = strconv.Atoi(value)
+		return err
+	},
+	"temp": func(order *resource.Order, value string) error {
+		order.Temp = value
+		return nil
+	},
+	"shelflife": func(order *resource.Order, value string) (err error) {
+		order.ShelfLife, err = strconv.Atoi(value)
+		return err
+	},
+	"decayrate": func(order *resource.Order, value string) (err error) {
+		order.DecayRate, err = strconv.ParseFloat(value, 64)
+		return err
+	},
+	"customerx": func(order *resource.Order, value string) (err error) {
+		customer := getCustomer(order)
+		customer.X, err = strconv.ParseFloat(value, 64)
+		return err
+	},
+	"customery": func(order *resource.Order, value string) (err error) {
+		customer := getCustomer(order)
+		customer.Y, err = strconv.ParseFloat(value, 64)
+		return err
+	},
+	"scheduledtime": func(order *resource.Order, value string) (err error) {
+		order.ScheduledTime, err = strconv.ParseFloat(value, 64)
+		return err
+	},
+}
+
+// csvAliases are the other names of some columns, by their normalized names
+var csvAliases = map[string]string{
+	"orderid":     "id",
+	"temperature": "temp",
+}
+
+// normalizeColumn gets the name of a column regardless of case, and of
+// underscores, dashes, and spaces between words (prepTime, prep_time, and
+// "Prep Time" are the same column)
+func normalizeColumn(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch r {
+		case '_', '-', ' ':
+			return -1
+		}
+		return r
+	}, strings.ToLower(strings.TrimSpace(name)))
+	if alias, ok := csvAliases[name]; ok {
+		return alias
+	}
+	return name
+}
+
+// getCustomer gets the customer location of the order, which is added if the
+// order has none yet
+func getCustomer(order *resource.Order) *resource.Location {
+	if order.Customer == nil {
+		order.Customer = &resource.Location{}
+	}
+	return order.Customer
+}
+
+// csvOrderStream decodes the orders of a CSV file, one per row after the
+// header row. Empty cells leave the fields of the order unset; a row with a
+// cell that cannot be decoded, or with a different number of cells than the
+// header row, is returned as a *ValidationError, and the stream goes on with
+// the next row
+type csvOrderStream struct {
+	r       *csv.Reader
+	columns []string // the normalized name of every column of the header row
+	index   int      // the number of rows read after the header row (blank lines aside)
+	closer  io.Closer
+}
+
+// start <private> reads the header row
+func (c *csvOrderStream) start() error {
+	header, err := c.r.Read()
+	if err != nil {
+		return err
+	}
+	c.columns = make([]string, len(header))
+	for i, name := range header {
+		c.columns[i] = normalizeColumn(name)
+	}
+	return nil
+}
+
+func (c *csvOrderStream) Next() (*resource.Order, error) {
+	if c.columns == nil {
+		if err := c.start(); err != nil {
+			return nil, err
+		}
+	}
+	values, err := c.r.Read()
+	if err != nil && !errors.Is(err, csv.ErrFieldCount) {
+		return nil, err
+	}
+	index := c.index
+	c.index++
+	if err != nil {
+		return nil, &ValidationError{Index: index, Reasons: []string{err.Error()}}
+	}
+	order := &resource.Order{}
+	var reasons []string
+	for i, value := range values {
+		set, ok := csvColumns[c.columns[i]]
+		if !ok || value == "" {
+			continue
+		}
+		if err := set(order, strings.TrimSpace(value)); err != nil {
+			reasons = append(reasons, fmt.Sprintf("invalid %s %q", c.columns[i], value))
+		}
+	}
+	if len(reasons) > 0 {
+		return nil, &ValidationError{Index: index, OrderID: order.ID, Reasons: reasons}
+	}
+	return order, nil
+}
+
+func (c *csvOrderStream) Close() error {
+	if c.closer == nil {
+		return nil
+	}
+	return c.closer.Close()
+}
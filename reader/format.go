@@ -0,0 +1,66 @@
+package reader
+
+import (
+	"bufio"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// Format is the format of the orders to read
+type Format string
+
+const (
+	// FormatJSON reads the orders as a JSON array, or as newline-delimited JSON
+	// if the input does not start with an array
+	FormatJSON Format = "json"
+	// FormatNDJSON reads the orders as newline-delimited JSON, one per line
+	FormatNDJSON Format = "ndjson"
+	// FormatCSV reads the orders as CSV, with a header row naming the columns
+	FormatCSV Format = "csv"
+)
+
+// ParseFormat parses the name of a format (json, ndjson, or csv)
+func ParseFormat(name string) (Format, error) {
+	switch format := Format(strings.ToLower(name)); format {
+	case FormatJSON, FormatNDJSON, FormatCSV:
+		return format, nil
+	}
+	return "", fmt.Errorf("unknown order format %q; expected json, ndjson, or csv", name)
+}
+
+// GetFormat gets the format of a file from its extension (.json, .ndjson or
+// .jsonl, or .csv); the standard input and a file without an extension (e.g.
+// /dev/stdin) are read as JSON
+func GetFormat(path string) (Format, error) {
+	extension := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+	switch {
+	case path == StdinPath, extension == "":
+		return FormatJSON, nil
+	case extension == "jsonl":
+		return FormatNDJSON, nil
+	}
+	if format, err := ParseFormat(extension); err == nil {
+		return format, nil
+	}
+	return "", fmt.Errorf("unknown format of %q; expected a .json, .ndjson, .jsonl, or .csv file", path)
+}
+
+// detectFormat <private> tells the format of the orders from the first
+// character of the input that is not a space: JSON if it is a bracket or a
+// brace, or CSV otherwise. The input is left as it was
+func detectFormat(r *bufio.Reader) Format {
+	for n := 1; ; n++ {
+		peeked, err := r.Peek(n)
+		if err != nil { // empty (or all spaces as far as it can tell)
+			return FormatJSON
+		}
+		switch c := peeked[n-1]; {
+		case c == '[' || c == '{':
+			return FormatJSON
+		case !unicode.IsSpace(rune(c)):
+			return FormatCSV
+		}
+	}
+}
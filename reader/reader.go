@@ -1,38 +1,81 @@
 package reader
 
 import (
-	"encoding/json"
-	"io/ioutil"
+	"bufio"
+	"io"
 	"os"
 
 	"wonsoh.private/cloudkitchens/resource"
 )
 
+// DefaultOrdersPath is the file the orders are read from by default, relative
+// to the working directory
+const DefaultOrdersPath = "resource/dispatch_orders.json"
+
+// StdinPath is the path that stands for the standard input
+const StdinPath = "-"
+
 // OrderReader is a reader that reads order
 type OrderReader interface {
 	ReadOrders() ([]*resource.Order, error)
 }
 
-type orderReaderImpl struct{}
+// readerOrderReader reads all the orders of a reader at once, through its
+// stream; an empty format is told by the content
+type readerOrderReader struct {
+	r      io.Reader
+	format Format
+}
+
+// fileOrderReader reads all the orders of a file at once, through its stream
+type fileOrderReader struct {
+	path string
+}
 
-func (o *orderReaderImpl) ReadOrders() ([]*resource.Order, error) {
-	ordersFile, err := os.Open("resource/dispatch_orders.json")
+func (o *readerOrderReader) ReadOrders() ([]*resource.Order, error) {
+	r, format := o.r, o.format
+	if format == "" {
+		buffered := bufio.NewReader(r)
+		r, format = buffered, detectFormat(buffered)
+	}
+	stream, err := GetFormatOrderStream(r, format)
 	if err != nil {
 		return nil, err
 	}
-	defer ordersFile.Close()
-	orderBytes, err := ioutil.ReadAll(ordersFile)
+	return readAll(stream)
+}
+
+func (f *fileOrderReader) ReadOrders() ([]*resource.Order, error) {
+	stream, err := GetFileOrderStream(f.path, "")
 	if err != nil {
 		return nil, err
 	}
-	orders := make([]*resource.Order, 10)
-	if e := json.Unmarshal(orderBytes, &orders); e != nil {
-		return nil, e
-	}
-	return orders, nil
+	defer stream.Close()
+	return readAll(stream)
 }
 
-// GetOrderReader constructs a new OrderReader instance
+// GetOrderReader constructs a new OrderReader instance that reads the orders
+// from DefaultOrdersPath
 func GetOrderReader() OrderReader {
-	return &orderReaderImpl{}
+	return GetFileOrderReader(DefaultOrdersPath)
+}
+
+// GetFileOrderReader constructs a new OrderReader instance that reads the
+// orders from the file at path, or from the standard input if path is
+// StdinPath, in the format GetFileOrderStream tells
+func GetFileOrderReader(path string) OrderReader {
+	return &fileOrderReader{path: path}
+}
+
+// GetReaderOrderReader constructs a new OrderReader instance that reads the
+// orders from r (e.g. a pipe) in the given format, or in the format told by
+// their content if it is empty
+func GetReaderOrderReader(r io.Reader, format Format) OrderReader {
+	return &readerOrderReader{r: r, format: format}
+}
+
+// GetStdinOrderReader constructs a new OrderReader instance that reads the
+// orders from the standard input, in the format told by their content
+func GetStdinOrderReader() OrderReader {
+	return GetReaderOrderReader(os.Stdin, "")
 }
@@ -0,0 +1,338 @@
+package reader
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"wonsoh.private/cloudkitchens/resource"
+)
+
+type ReaderTestSuite struct {
+	suite.Suite
+	orders []*resource.Order
+}
+
+func (r *ReaderTestSuite) SetupTest() {
+	r.orders = []*resource.Order{
+		{ID: "1", Name: "Banana Split", PrepTime: 4, Temp: resource.TemperatureFrozen, ShelfLife: 20, DecayRate: 0.5},
+		{ID: "2", Name: "Burger, Large", PrepTime: 6, Customer: &resource.Location{X: 10, Y: -20}, ScheduledTime: 2.5},
+	}
+}
+
+// readStream reads the orders of the input in the format
+func (r *ReaderTestSuite) readStream(input string, format Format) ([]*resource.Order, error) {
+	stream, err := GetFormatOrderStream(strings.NewReader(input), format)
+	r.NoError(err)
+	defer stream.Close()
+	return readAll(stream)
+}
+
+func (r *ReaderTestSuite) TestJSON() {
+	orders, err := r.readStream(`[
+		{"id": "1", "name": "Banana Split", "prepTime": 4, "temp": "frozen", "shelfLife": 20, "decayRate": 0.5},
+		{"id": "2", "name": "Burger, Large", "prepTime": 6, "customer": {"x": 10, "y": -20}, "scheduledTime": 2.5}
+	]`, FormatJSON)
+	r.NoError(err)
+	r.Equal(r.orders, orders)
+
+	orders, err = r.readStream(" [] ", FormatJSON)
+	r.NoError(err)
+	r.Empty(orders)
+	_, err = r.readStream(`[{"id": "1"},`, FormatJSON)
+	r.Error(err) // truncated
+	_, err = r.readStream(`[{"id": "1"}`, FormatJSON)
+	r.Error(err) // the closing bracket is missing
+}
+
+func (r *ReaderTestSuite) TestNDJSON() {
+	input := `{"id": "1", "name": "Banana Split", "prepTime": 4, "temp": "frozen", "shelfLife": 20, "decayRate": 0.5}
+{"id": "2", "name": "Burger, Large", "prepTime": 6, "customer": {"x": 10, "y": -20}, "scheduledTime": 2.5}
+`
+	for _, format := range []Format{FormatNDJSON, FormatJSON} { // told from the first line
+		orders, err := r.readStream(input, format)
+		r.NoError(err)
+		r.Equal(r.orders, orders)
+	}
+	_, err := r.readStream(`[{"id": "1"}]`, FormatNDJSON)
+	r.Error(err) // not an order per line
+}
+
+func (r *ReaderTestSuite) TestCSV() {
+	orders, err := r.readStream(`Order ID,name,Prep Time,Temperature,shelf_life,decayRate,customer_x,customer_y,scheduled_time,notes
+1,Banana Split,4,frozen,20,0.5,,,,ignored
+2,"Burger, Large", 6 ,,,,10,-20,2.5,
+`, FormatCSV)
+	r.NoError(err)
+	r.Equal(r.orders, orders)
+
+	_, err = r.readStream("id,prepTime\n1,4\n2,soon\n", FormatCSV)
+	r.EqualError(err, `invalid order #1 (ID "2"): invalid preptime "soon"`)
+	_, err = r.readStream("\nid,prepTime\n\n1,4\n\n\n2,soon\n", FormatCSV)
+	r.EqualError(err, `invalid order #1 (ID "2"): invalid preptime "soon"`) // the blank lines are not orders
+	_, err = r.readStream("id,prepTime\n1,4,5\n", FormatCSV)
+	r.IsType(&ValidationError{}, err)
+	orders, err = r.readStream("", FormatCSV)
+	r.NoError(err) // not even a header row
+	r.Empty(orders)
+}
+
+func (r *ReaderTestSuite) TestIncremental() {
+	// every order is decoded as soon as it has come, before the rest of the input
+	for _, tc := range []struct {
+		format Format
+		first  string
+		rest   string
+	}{
+		{FormatJSON, `[{"id": "1", "name": "Banana Split", "prepTime": 4}, `, `{"id": "2", "name": "Burger", "prepTime": 6}]`},
+		{FormatJSON, `{"id": "1", "name": "Banana Split", "prepTime": 4}` + "\n", `{"id": "2", "name": "Burger", "prepTime": 6}` + "\n"},
+		{FormatNDJSON, `{"id": "1", "name": "Banana Split", "prepTime": 4}` + "\n", `{"id": "2", "name": "Burger", "prepTime": 6}` + "\n"},
+		{FormatCSV, "id,name,prepTime\n1,Banana Split,4\n", "2,Burger,6\n"},
+	} {
+		pr, pw := io.Pipe()
+		more := make(chan struct{})
+		go func() {
+			pw.Write([]byte(tc.first))
+			<-more
+			pw.Write([]byte(tc.rest))
+			pw.Close()
+		}()
+		stream, err := GetFormatOrderStream(pr, tc.format)
+		r.Require().NoError(err)
+		order, err := stream.Next()
+		r.NoError(err, tc.first)
+		r.Equal("1", order.ID, tc.first)
+		close(more)
+		order, err = stream.Next()
+		r.NoError(err, tc.first)
+		r.Equal("2", order.ID, tc.first)
+		_, err = stream.Next()
+		r.Equal(io.EOF, err, tc.first)
+	}
+}
+
+func (r *ReaderTestSuite) TestGetFormat() {
+	for path, expected := range map[string]Format{
+		"orders.json":  FormatJSON,
+		"orders.JSONL": FormatNDJSON,
+		"a/b.ndjson":   FormatNDJSON,
+		"orders.csv":   FormatCSV,
+		StdinPath:      FormatJSON,
+		"/dev/stdin":   FormatJSON,
+	} {
+		format, err := GetFormat(path)
+		r.NoError(err, path)
+		r.Equal(expected, format, path)
+	}
+	_, err := GetFormat("orders.txt")
+	r.Error(err)
+
+	format, err := ParseFormat("CSV")
+	r.NoError(err)
+	r.Equal(FormatCSV, format)
+	_, err = ParseFormat("xml")
+	r.Error(err)
+	_, err = GetFormatOrderStream(strings.NewReader(""), "xml")
+	r.Error(err)
+}
+
+func (r *ReaderTestSuite) TestFileOrderReader() {
+	path := filepath.Join(r.T().TempDir(), "orders.csv")
+	r.NoError(os.WriteFile(path, []byte("id,name,prepTime\n1,Banana Split,4\n"), 0o644))
+	orders, err := GetFileOrderReader(path).ReadOrders()
+	r.NoError(err)
+	r.Equal([]*resource.Order{{ID: "1", Name: "Banana Split", PrepTime: 4}}, orders)
+
+	stream, err := GetFileOrderStream(path, FormatJSON) // the format overrides the extension
+	r.NoError(err)
+	_, err = stream.Next()
+	r.Error(err)
+	r.NoError(stream.Close())
+
+	_, err = GetFileOrderReader(filepath.Join(r.T().TempDir(), "missing.json")).ReadOrders()
+	r.True(os.IsNotExist(err))
+}
+
+func (r *ReaderTestSuite) TestReaderOrderReader() {
+	for format, input := range map[Format]string{
+		FormatJSON:   `[{"id": "1", "name": "Banana Split", "prepTime": 4}]`,
+		FormatNDJSON: `{"id": "1", "name": "Banana Split", "prepTime": 4}` + "\n",
+		FormatCSV:    "id,name,prepTime\n1,Banana Split,4\n",
+	} {
+		for _, given := range []Format{format, ""} { // or told by the content
+			orders, err := GetReaderOrderReader(strings.NewReader(input), given).ReadOrders()
+			r.NoError(err, format)
+			r.Equal([]*resource.Order{{ID: "1", Name: "Banana Split", PrepTime: 4}}, orders, format)
+		}
+	}
+	_, err := GetReaderOrderReader(strings.NewReader("id\n1\n"), FormatJSON).ReadOrders()
+	r.Error(err)
+	_, err = GetReaderOrderReader(strings.NewReader(""), "xml").ReadOrders()
+	r.Error(err)
+}
+
+func (r *ReaderTestSuite) TestStdin() {
+	path := filepath.Join(r.T().TempDir(), "orders")
+	r.NoError(os.WriteFile(path, []byte("id,name,prepTime\n1,Banana Split,4\n"), 0o644))
+	stdin := os.Stdin
+	defer func() {
+		os.Stdin = stdin
+	}()
+	for _, getReader := range []func() OrderReader{
+		GetStdinOrderReader,
+		func() OrderReader {
+			return GetFileOrderReader(StdinPath)
+		},
+	} {
+		file, err := os.Open(path)
+		r.Require().NoError(err)
+		os.Stdin = file
+		orders, err := getReader().ReadOrders()
+		r.NoError(err)
+		r.Equal([]*resource.Order{{ID: "1", Name: "Banana Split", PrepTime: 4}}, orders)
+		file.Close()
+	}
+}
+
+func (r *ReaderTestSuite) TestDetectFormat() {
+	// a file without an extension (e.g. /dev/stdin) is told by its content
+	dir := r.T().TempDir()
+	for name, content := range map[string]string{
+		"array": "\n [{\"id\": \"1\", \"name\": \"Banana Split\", \"prepTime\": 4}]",
+		"lines": "{\"id\": \"1\", \"name\": \"Banana Split\", \"prepTime\": 4}\n",
+		"csv":   "id,name,prepTime\n1,Banana Split,4\n",
+	} {
+		path := filepath.Join(dir, name)
+		r.NoError(os.WriteFile(path, []byte(content), 0o644))
+		orders, err := GetFileOrderReader(path).ReadOrders()
+		r.NoError(err, name)
+		r.Equal([]*resource.Order{{ID: "1", Name: "Banana Split", PrepTime: 4}}, orders, name)
+	}
+
+	path := filepath.Join(dir, "empty")
+	r.NoError(os.WriteFile(path, []byte(" \n"), 0o644))
+	orders, err := GetFileOrderReader(path).ReadOrders()
+	r.NoError(err)
+	r.Empty(orders)
+}
+
+func (r *ReaderTestSuite) TestOrderReader() {
+	wd, err := os.Getwd()
+	r.Require().NoError(err)
+	r.Require().NoError(os.Chdir(".."))
+	defer os.Chdir(wd)
+	orders, err := GetOrderReader().ReadOrders()
+	r.NoError(err)
+	r.Len(orders, 132)
+	r.Empty(ValidateOrders(orders))
+}
+
+func (r *ReaderTestSuite) TestValidateOrders() {
+	invalid := ValidateOrders([]*resource.Order{
+		r.orders[0],
+		nil,
+		{ID: "1", Name: "Banana Split", PrepTime: 4},
+		{Name: " ", PrepTime: -1, Temp: "warm", ShelfLife: -1, DecayRate: -1, ScheduledTime: -1},
+		r.orders[1],
+	})
+	r.Equal([]*ValidationError{
+		{Index: 1, Reasons: []string{"no order"}},
+		{Index: 2, OrderID: "1", Reasons: []string{"duplicate ID"}},
+		{Index: 3, Reasons: []string{
+			"empty ID",
+			"empty name",
+			"prep time -1 is not positive",
+			`unknown temperature "warm"`,
+			"shelf life -1 is negative",
+			"decay rate -1 is negative",
+			"scheduled time -1 is negative",
+		}},
+	}, invalid)
+	r.EqualError(invalid[1], `invalid order #2 (ID "1"): duplicate ID`)
+	r.Empty(ValidateOrders(r.orders))
+}
+
+func (r *ReaderTestSuite) TestValidatingStream() {
+	input := `{"id": "1", "name": "Banana Split", "prepTime": 4}
+{"id": "1", "name": "Banana Split", "prepTime": 4}
+{"id": "2", "name": "Burger", "prepTime": 0}
+{"id": "2", "name": "Burger", "prepTime": 6}
+`
+	stream := GetUniqueValidatingStream(GetReaderOrderStream(strings.NewReader(input)), ValidationStrict, nil)
+	order, err := stream.Next()
+	r.NoError(err)
+	r.Equal("1", order.ID)
+	_, err = stream.Next()
+	var invalid *ValidationError
+	r.True(errors.As(err, &invalid)) // stops at the duplicate
+	r.Equal(1, invalid.Index)
+
+	var reported []int
+	stream = GetUniqueValidatingStream(GetReaderOrderStream(strings.NewReader(input)), ValidationLenient, func(err *ValidationError) {
+		reported = append(reported, err.Index)
+	})
+	orders, err := readAll(stream)
+	r.NoError(err)
+	r.Len(orders, 2) // the second 2 is valid, since the first one was skipped
+	r.Equal([]int{1, 2}, reported)
+	r.Equal(2, stream.GetSkippedCount())
+
+	// without keeping the IDs, the duplicates are left to the order manager
+	reported = nil
+	stream = GetValidatingStream(GetReaderOrderStream(strings.NewReader(input)), ValidationLenient, func(err *ValidationError) {
+		reported = append(reported, err.Index)
+	})
+	orders, err = readAll(stream)
+	r.NoError(err)
+	r.Len(orders, 3)
+	r.Equal([]int{2}, reported)
+	r.Nil(stream.(*validatingStream).validator.seen)
+
+	// the orders that cannot be decoded are skipped as well
+	for format, input := range map[Format]string{
+		FormatCSV: "id,name,prepTime\na,Apple,4\n\nb,Bar,abc\n\n\nc,Cake\nd,Donut,5\n", // with blank lines
+		FormatNDJSON: `{"id": "a", "name": "Apple", "prepTime": 4}
+{"id": "b", "name": "Bar", "prepTime": "abc"}
+{"id": "c", "name": "Cake", "prepTime": [5]}
+{"id": "d", "name": "Donut", "prepTime": 5}
+`,
+		FormatJSON: `[{"id": "a", "name": "Apple", "prepTime": 4}, {"id": "b", "name": "Bar", "prepTime": "abc"},
+			{"id": "c", "name": "Cake", "prepTime": 5, "temp": 1}, {"id": "d", "name": "Donut", "prepTime": 5}]`,
+	} {
+		decoded, err := GetFormatOrderStream(strings.NewReader(input), format)
+		r.Require().NoError(err)
+		reported = nil
+		stream = GetValidatingStream(decoded, ValidationLenient, func(err *ValidationError) {
+			reported = append(reported, err.Index)
+		})
+		orders, err = readAll(stream)
+		r.NoError(err, format)
+		r.Len(orders, 2, format)
+		r.Equal("d", orders[1].ID, format)
+		r.Equal([]int{1, 2}, reported, format)
+		r.Equal(2, stream.GetSkippedCount(), format)
+
+		decoded, _ = GetFormatOrderStream(strings.NewReader(input), format)
+		stream = GetValidatingStream(decoded, ValidationStrict, nil)
+		_, err = readAll(stream)
+		r.True(errors.As(err, &invalid), format)
+		r.Equal(1, invalid.Index, format)
+		r.Equal("b", invalid.OrderID, format)
+	}
+	_, err = readAll(GetValidatingStream(GetReaderOrderStream(strings.NewReader(`[{"id": "1"`)), ValidationLenient, nil))
+	r.Error(err) // a syntax error ends the input even in lenient mode
+
+	mode, err := ParseValidationMode("Lenient")
+	r.NoError(err)
+	r.Equal(ValidationLenient, mode)
+	_, err = ParseValidationMode("loose")
+	r.Error(err)
+}
+
+func TestReaderTestSuite(t *testing.T) {
+	suite.Run(t, new(ReaderTestSuite))
+}
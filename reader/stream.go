@@ -0,0 +1,170 @@
+package reader
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"unicode"
+
+	"wonsoh.private/cloudkitchens/resource"
+)
+
+// OrderStream yields the orders one at a time as they are decoded, so that a
+// large input never has to be held in memory at once
+type OrderStream interface {
+	// Next decodes the next order; returns io.EOF once there are no more, or a
+	// *ValidationError for an order that cannot be decoded, after which Next
+	// goes on with the one after it
+	Next() (*resource.Order, error)
+	// Close releases the input of the stream
+	Close() error
+}
+
+// jsonOrderStream decodes the orders of a JSON array, or of newline-delimited
+// JSON (one order per line), whichever the input starts with. An order with a
+// field of the wrong type (e.g. a prep time in quotes) is returned as a
+// *ValidationError, and the stream goes on with the next order; the stream
+// cannot go on after a syntax error, since the next order cannot be told apart
+type jsonOrderStream struct {
+	r       *bufio.Reader
+	decoder *json.Decoder
+	lines   bool // whether the input can only be newline-delimited JSON
+	array   bool // whether the orders are the elements of a JSON array
+	index   int  // the number of orders decoded so far
+	closer  io.Closer
+}
+
+// start <private> finds out whether the input is a JSON array, and if so
+// decodes the opening bracket
+func (j *jsonOrderStream) start() error {
+	for {
+		c, _, err := j.r.ReadRune()
+		if err != nil {
+			return err
+		}
+		if !unicode.IsSpace(c) {
+			if err := j.r.UnreadRune(); err != nil {
+				return err
+			}
+			j.decoder = json.NewDecoder(j.r)
+			if j.array = c == '[' && !j.lines; j.array {
+				_, err = j.decoder.Token()
+			}
+			return err
+		}
+	}
+}
+
+func (j *jsonOrderStream) Next() (*resource.Order, error) {
+	if j.decoder == nil {
+		if err := j.start(); err != nil {
+			return nil, err
+		}
+	}
+	if j.array && !j.decoder.More() { // the closing bracket
+		if token, err := j.decoder.Token(); err != nil {
+			return nil, err
+		} else if token != json.Delim(']') {
+			return nil, fmt.Errorf("unexpected %v at the end of the orders", token)
+		}
+		return nil, io.EOF
+	}
+	var order resource.Order
+	err := j.decoder.Decode(&order)
+	var mistyped *json.UnmarshalTypeError
+	if errors.As(err, &mistyped) { // the whole order is decoded past
+		j.index++
+		return nil, &ValidationError{Index: j.index - 1, OrderID: order.ID, Reasons: []string{err.Error()}}
+	}
+	if err != nil {
+		return nil, err
+	}
+	j.index++
+	return &order, nil
+}
+
+func (j *jsonOrderStream) Close() error {
+	if j.closer == nil {
+		return nil
+	}
+	return j.closer.Close()
+}
+
+// readAll <private> reads the rest of the stream
+func readAll(stream OrderStream) ([]*resource.Order, error) {
+	var orders []*resource.Order
+	for {
+		order, err := stream.Next()
+		if err == io.EOF {
+			return orders, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+}
+
+// getOrderStream <private> gets the stream of the orders of r in the format,
+// which closes the closer (if any) once it is closed
+func getOrderStream(r io.Reader, format Format, closer io.Closer) (OrderStream, error) {
+	switch format {
+	case FormatJSON, FormatNDJSON:
+		return &jsonOrderStream{r: bufio.NewReader(r), lines: format == FormatNDJSON, closer: closer}, nil
+	case FormatCSV:
+		return &csvOrderStream{r: csv.NewReader(r), closer: closer}, nil
+	}
+	return nil, fmt.Errorf("unknown order format %q", format)
+}
+
+// GetReaderOrderStream constructs a new OrderStream instance that decodes the
+// orders from r as they come as JSON, e.g. from a pipe; closing it leaves r
+// open
+func GetReaderOrderStream(r io.Reader) OrderStream {
+	stream, _ := getOrderStream(r, FormatJSON, nil)
+	return stream
+}
+
+// GetFormatOrderStream constructs a new OrderStream instance that decodes the
+// orders from r as they come in the given format; closing it leaves r open
+func GetFormatOrderStream(r io.Reader, format Format) (OrderStream, error) {
+	return getOrderStream(r, format, nil)
+}
+
+// GetFileOrderStream constructs a new OrderStream instance that decodes the
+// orders from the file at path, or from the standard input if path is
+// StdinPath, in the given format. An empty format is told by the extension of
+// the file (see GetFormat), or by the content of the standard input or of a
+// file without an extension (e.g. /dev/stdin, or a pipe)
+func GetFileOrderStream(path string, format Format) (OrderStream, error) {
+	detect := format == "" && (path == StdinPath || filepath.Ext(path) == "")
+	if format == "" && !detect {
+		var err error
+		if format, err = GetFormat(path); err != nil {
+			return nil, err
+		}
+	}
+	var r io.Reader = os.Stdin
+	var closer io.Closer
+	if path != StdinPath {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		r, closer = file, file
+	}
+	if detect {
+		buffered := bufio.NewReader(r)
+		r, format = buffered, detectFormat(buffered)
+	}
+	stream, err := getOrderStream(r, format, closer)
+	if err != nil && closer != nil {
+		closer.Close()
+	}
+	return stream, err
+}
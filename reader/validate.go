@@ -0,0 +1,189 @@
+package reader
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"wonsoh.private/cloudkitchens/resource"
+)
+
+// ValidationMode is what happens to the invalid orders of the input
+type ValidationMode string
+
+const (
+	// ValidationStrict stops reading at the first invalid order
+	ValidationStrict ValidationMode = "strict"
+	// ValidationLenient skips the invalid orders, and counts them
+	ValidationLenient ValidationMode = "lenient"
+)
+
+// ParseValidationMode parses the name of a validation mode (strict or lenient)
+func ParseValidationMode(name string) (ValidationMode, error) {
+	switch mode := ValidationMode(strings.ToLower(name)); mode {
+	case ValidationStrict, ValidationLenient:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown validation mode %q; expected strict or lenient", name)
+}
+
+// ValidationError reports an invalid order of the input, and every reason it
+// is invalid
+type ValidationError struct {
+	// Index is the position of the order in the input, from 0
+	Index int
+	// OrderID is the ID of the order (empty if it has none)
+	OrderID string
+	// Reasons are the reasons the order is invalid
+	Reasons []string
+}
+
+func (v *ValidationError) Error() string {
+	return fmt.Sprintf("invalid order #%d (ID %q): %s", v.Index, v.OrderID, strings.Join(v.Reasons, "; "))
+}
+
+// orderValidator checks the orders of the input one after another. If it
+// keeps the IDs of the valid orders (seen is not nil), an order is a duplicate
+// if an earlier valid one has the same ID
+type orderValidator struct {
+	index int
+	seen  map[string]bool
+}
+
+// validate <private> checks the next order of the input; returns nil if it is
+// valid
+func (o *orderValidator) validate(order *resource.Order) *ValidationError {
+	index := o.index
+	o.index++
+	if order == nil {
+		return &ValidationError{Index: index, Reasons: []string{"no order"}}
+	}
+	var reasons []string
+	if order.ID == "" {
+		reasons = append(reasons, "empty ID")
+	} else if o.seen != nil && o.seen[order.ID] {
+		reasons = append(reasons, "duplicate ID")
+	}
+	if strings.TrimSpace(order.Name) == "" {
+		reasons = append(reasons, "empty name")
+	}
+	if order.PrepTime <= 0 {
+		reasons = append(reasons, fmt.Sprintf("prep time %d is not positive", order.PrepTime))
+	}
+	switch order.Temp {
+	case "", resource.TemperatureHot, resource.TemperatureCold, resource.TemperatureFrozen:
+	default:
+		reasons = append(reasons, fmt.Sprintf("unknown temperature %q", order.Temp))
+	}
+	if order.ShelfLife < 0 {
+		reasons = append(reasons, fmt.Sprintf("shelf life %d is negative", order.ShelfLife))
+	}
+	if order.DecayRate < 0 {
+		reasons = append(reasons, fmt.Sprintf("decay rate %v is negative", order.DecayRate))
+	}
+	if order.ScheduledTime < 0 {
+		reasons = append(reasons, fmt.Sprintf("scheduled time %v is negative", order.ScheduledTime))
+	}
+	if len(reasons) > 0 {
+		return &ValidationError{Index: index, OrderID: order.ID, Reasons: reasons}
+	}
+	if o.seen != nil {
+		o.seen[order.ID] = true
+	}
+	return nil
+}
+
+// ValidatingStream is an OrderStream that checks the orders of another one
+type ValidatingStream interface {
+	OrderStream
+	// GetSkippedCount gets the number of invalid orders skipped so far (in
+	// lenient mode)
+	GetSkippedCount() int
+}
+
+// validatingStream checks the orders of a stream as they are decoded
+type validatingStream struct {
+	OrderStream
+	validator *orderValidator
+	mode      ValidationMode
+	report    func(err *ValidationError)
+	skipped   int
+}
+
+// Next decodes the next valid order. In strict mode, an invalid order (or one
+// that the stream cannot decode) is returned as a *ValidationError; in lenient
+// mode, it is reported and skipped
+func (v *validatingStream) Next() (*resource.Order, error) {
+	for {
+		order, err := v.OrderStream.Next()
+		var invalid *ValidationError
+		if errors.As(err, &invalid) { // malformed, but the stream goes on
+			v.validator.index++
+		} else if err != nil {
+			return nil, err
+		} else if invalid = v.validator.validate(order); invalid == nil {
+			return order, nil
+		}
+		if v.mode != ValidationLenient {
+			return nil, invalid
+		}
+		v.skipped++
+		if v.report != nil {
+			v.report(invalid)
+		}
+	}
+}
+
+func (v *validatingStream) GetSkippedCount() int {
+	return v.skipped
+}
+
+// ValidateOrders checks all the orders, including that their IDs are unique;
+// returns an error for every invalid one, in the order of the input
+func ValidateOrders(orders []*resource.Order) []*ValidationError {
+	validator := getOrderValidator(true)
+	var invalid []*ValidationError
+	for _, order := range orders {
+		if err := validator.validate(order); err != nil {
+			invalid = append(invalid, err)
+		}
+	}
+	return invalid
+}
+
+// getOrderValidator <private> gets a validator that keeps the IDs of the valid
+// orders to reject duplicates if unique is set
+func getOrderValidator(unique bool) *orderValidator {
+	validator := &orderValidator{}
+	if unique {
+		validator.seen = map[string]bool{}
+	}
+	return validator
+}
+
+// GetValidatingStream constructs a new ValidatingStream instance that checks
+// the orders of the stream in the given mode, each on its own so that it takes
+// the same memory however large the input is; in lenient mode, report (if not
+// nil) is called with every invalid order skipped
+func GetValidatingStream(stream OrderStream, mode ValidationMode, report func(err *ValidationError)) ValidatingStream {
+	return &validatingStream{
+		OrderStream: stream,
+		validator:   getOrderValidator(false),
+		mode:        mode,
+		report:      report,
+	}
+}
+
+// GetUniqueValidatingStream constructs a new ValidatingStream instance that
+// checks the orders of the stream as GetValidatingStream does, and also
+// rejects an order with the ID of an earlier valid one. The IDs of all the
+// valid orders are kept for that, so that they take memory for every order of
+// the input
+func GetUniqueValidatingStream(stream OrderStream, mode ValidationMode, report func(err *ValidationError)) ValidatingStream {
+	return &validatingStream{
+		OrderStream: stream,
+		validator:   getOrderValidator(true),
+		mode:        mode,
+		report:      report,
+	}
+}
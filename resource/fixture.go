@@ -1,6 +1,7 @@
 package resource
 
 import (
+	"math"
 	"math/rand"
 	"time"
 
@@ -15,6 +16,15 @@ const (
 	MinTravelTime = 3
 )
 
+const (
+	// TemperatureHot is the temperature of food kept on the hot shelf
+	TemperatureHot = "hot"
+	// TemperatureCold is the temperature of food kept on the cold shelf
+	TemperatureCold = "cold"
+	// TemperatureFrozen is the temperature of food kept on the frozen shelf
+	TemperatureFrozen = "frozen"
+)
+
 // Order represents an object of order dispatched
 type Order struct {
 	// ID is an identifier of the courier
@@ -23,6 +33,33 @@ type Order struct {
 	Name string `json:"name"`
 	// PrepTime is the preparation time in seconds
 	PrepTime int `json:"prepTime"`
+	// Temp is the temperature the food has to be kept at (hot, cold, or frozen);
+	// food without a temperature can only be kept on the overflow shelf
+	Temp string `json:"temp"`
+	// ShelfLife is the time in seconds the food stays fresh once prepared;
+	// food without a shelf life never goes stale
+	ShelfLife int `json:"shelfLife"`
+	// DecayRate is how much faster than the time itself the food goes stale
+	DecayRate float64 `json:"decayRate"`
+	// Customer is where the order is delivered to; orders without a customer
+	// location are delivered to a random one (with a geographic model only)
+	Customer *Location `json:"customer,omitempty"`
+	// ScheduledTime is the time in seconds after dispatching started that the
+	// order is dispatched at; orders without a scheduled time are dispatched
+	// at the dispatch rate
+	ScheduledTime float64 `json:"scheduledTime,omitempty"`
+}
+
+// Location is a point on the grid of the streets around the kitchen, in meters
+type Location struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// Distance gets the distance in meters to travel along the streets (i.e. the
+// Manhattan distance) to the other location
+func (l Location) Distance(other Location) float64 {
+	return math.Abs(l.X-other.X) + math.Abs(l.Y-other.Y)
 }
 
 // Courier represents a courier to pick-up an order
@@ -33,6 +70,49 @@ type Courier struct {
 	OrderID string `json:"order_id"`
 	// TravelTime is the time for courier to travel
 	TravelTime int `json:"travelTime"`
+	// DeliveryTime is the time for courier to deliver the order to the customer
+	DeliveryTime int `json:"deliveryTime"`
+	// ReturnTime is the time for courier to come back after delivering the
+	// order; only couriers of a limited fleet come back
+	ReturnTime int `json:"returnTime"`
+	// Location is where the courier sets off to the kitchen from (with a
+	// geographic model only)
+	Location *Location `json:"location,omitempty"`
+	// Distance is the distance in meters from where the courier sets off to
+	// the kitchen
+	Distance float64 `json:"distance"`
+	// DeliveryDistance is the distance in meters from the kitchen to the
+	// customer of the order the courier picked up
+	DeliveryDistance float64 `json:"deliveryDistance"`
+	// NoShow is whether the courier never shows up at the kitchen (e.g.
+	// abandons the trip), and has to be replaced
+	NoShow bool `json:"noShow"`
+}
+
+// OrderRecord is the record of an order that has been picked up
+type OrderRecord struct {
+	// OrderID is the identifier of the order
+	OrderID string `json:"orderId"`
+	// Name is the name of the order
+	Name string `json:"name"`
+	// PrepTime is the preparation time in seconds
+	PrepTime int `json:"prepTime"`
+	// CourierID is the identifier of the courier that picked up the order
+	CourierID string `json:"courierId"`
+	// TravelTime is the time in seconds for the courier to travel
+	TravelTime int `json:"travelTime"`
+	// DispatchedAt is when the order was dispatched
+	DispatchedAt time.Time `json:"dispatchedAt"`
+	// ReadyAt is when the food was prepared
+	ReadyAt time.Time `json:"readyAt"`
+	// ArrivedAt is when the courier arrived
+	ArrivedAt time.Time `json:"arrivedAt"`
+	// PickedUpAt is when the courier picked up the food
+	PickedUpAt time.Time `json:"pickedUpAt"`
+	// FoodWaitTime is the time in ms the food waited for the courier
+	FoodWaitTime int `json:"foodWaitTime"`
+	// CourierWaitTime is the time in ms the courier waited for the food
+	CourierWaitTime int `json:"courierWaitTime"`
 }
 
 // NewCourier constructs a new courier structure
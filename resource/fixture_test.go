@@ -50,6 +50,13 @@ func (t *FixtureTestSuite) TestGetCourierTravelTime() {
 	}
 }
 
+func (t *FixtureTestSuite) TestLocationDistance() {
+	kitchen := Location{X: 10, Y: -5}
+	t.EqualValues(0, kitchen.Distance(kitchen))
+	t.EqualValues(35, kitchen.Distance(Location{X: -10, Y: 10}))
+	t.EqualValues(35, Location{X: -10, Y: 10}.Distance(kitchen))
+}
+
 func TestFixtureTestSuite(t *testing.T) {
 	suite.Run(t, new(FixtureTestSuite))
 }
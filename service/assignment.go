@@ -0,0 +1,87 @@
+package service
+
+import "math"
+
+// solveAssignment solves the minimum-cost assignment of the rows to the columns
+// of the cost matrix with the Hungarian algorithm, in O(n^2 m) time for n rows
+// and m columns. Returns the column assigned to every row; when there are more
+// rows than columns, the rows left out are assigned -1
+func solveAssignment(cost [][]float64) []int {
+	n := len(cost)
+	if n == 0 {
+		return nil
+	}
+	m := len(cost[0])
+	if n > m { // solve it the other way around, so that every row is assigned
+		transposed := make([][]float64, m)
+		for j := range transposed {
+			transposed[j] = make([]float64, n)
+			for i := range cost {
+				transposed[j][i] = cost[i][j]
+			}
+		}
+		assignment := make([]int, n)
+		for i := range assignment {
+			assignment[i] = -1
+		}
+		for j, i := range solveAssignment(transposed) {
+			assignment[i] = j
+		}
+		return assignment
+	}
+
+	// potentials of the rows and the columns, and the row assigned to every
+	// column; row and column 0 are sentinels, so the others are 1-indexed
+	u, v := make([]float64, n+1), make([]float64, m+1)
+	assigned, way := make([]int, m+1), make([]int, m+1)
+	for i := 1; i <= n; i++ {
+		// finds the shortest augmenting path from row i to a free column
+		assigned[0] = i
+		column := 0
+		minimum := make([]float64, m+1)
+		used := make([]bool, m+1)
+		for j := range minimum {
+			minimum[j] = math.Inf(1)
+		}
+		for {
+			used[column] = true
+			row, delta, next := assigned[column], math.Inf(1), 0
+			for j := 1; j <= m; j++ {
+				if used[j] {
+					continue
+				}
+				if reduced := cost[row-1][j-1] - u[row] - v[j]; reduced < minimum[j] {
+					minimum[j], way[j] = reduced, column
+				}
+				if minimum[j] < delta {
+					delta, next = minimum[j], j
+				}
+			}
+			for j := 0; j <= m; j++ {
+				if used[j] {
+					u[assigned[j]] += delta
+					v[j] -= delta
+				} else {
+					minimum[j] -= delta
+				}
+			}
+			column = next
+			if assigned[column] == 0 { // a free column has been reached
+				break
+			}
+		}
+		for column != 0 { // flips the assignments along the path
+			previous := way[column]
+			assigned[column] = assigned[previous]
+			column = previous
+		}
+	}
+
+	assignment := make([]int, n)
+	for j := 1; j <= m; j++ {
+		if assigned[j] != 0 {
+			assignment[assigned[j]-1] = j - 1
+		}
+	}
+	return assignment
+}
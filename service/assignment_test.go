@@ -0,0 +1,92 @@
+package service
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"wonsoh.private/cloudkitchens/resource"
+)
+
+type AssignmentTestSuite struct {
+	suite.Suite
+}
+
+// getTotalCost gets the total cost of an assignment
+func getTotalCost(cost [][]float64, assignment []int) float64 {
+	total := 0.0
+	for i, j := range assignment {
+		if j >= 0 {
+			total += cost[i][j]
+		}
+	}
+	return total
+}
+
+// getMinimumCost gets the minimum total cost of assigning the rows (at most as
+// many as the columns) by trying every assignment
+func getMinimumCost(cost [][]float64, row int, used []bool) float64 {
+	if row == len(cost) {
+		return 0
+	}
+	minimum := math.Inf(1)
+	for j := range used {
+		if !used[j] {
+			used[j] = true
+			minimum = math.Min(minimum, cost[row][j]+getMinimumCost(cost, row+1, used))
+			used[j] = false
+		}
+	}
+	return minimum
+}
+
+func (a *AssignmentTestSuite) TestSolveAssignment() {
+	cost := [][]float64{
+		{4, 1, 3},
+		{2, 0, 5},
+		{3, 2, 2},
+	}
+	a.Equal([]int{1, 0, 2}, solveAssignment(cost))
+	a.Nil(solveAssignment(nil))
+}
+
+func (a *AssignmentTestSuite) TestRectangular() {
+	wide := [][]float64{
+		{9, 1, 5},
+		{1, 2, 8},
+	}
+	a.Equal([]int{1, 0}, solveAssignment(wide))
+	tall := [][]float64{
+		{9, 1},
+		{1, 2},
+		{0, 0},
+	}
+	assignment := solveAssignment(tall)
+	a.EqualValues(1, getTotalCost(tall, assignment))
+	a.Contains(assignment, -1) // a row is left out
+}
+
+func (a *AssignmentTestSuite) TestOptimal() {
+	random := resource.GetFixedSeedRandomNumberGenerator()
+	for trial := 0; trial < 50; trial++ {
+		n, m := random.Intn(6)+1, 6
+		cost := make([][]float64, n)
+		for i := range cost {
+			cost[i] = make([]float64, m)
+			for j := range cost[i] {
+				cost[i][j] = float64(random.Intn(100))
+			}
+		}
+		assignment := solveAssignment(cost)
+		seen := map[int]bool{}
+		for _, j := range assignment {
+			a.False(seen[j]) // every column is assigned once at most
+			seen[j] = true
+		}
+		a.InDelta(getMinimumCost(cost, 0, make([]bool, m)), getTotalCost(cost, assignment), 1e-9)
+	}
+}
+
+func TestAssignmentTestSuite(t *testing.T) {
+	suite.Run(t, new(AssignmentTestSuite))
+}
@@ -0,0 +1,279 @@
+package service
+
+import (
+	"container/list"
+	"time"
+)
+
+// BatchStrategyName is the name of the strategy that matches the orders and
+// couriers in batches, with the minimum combined wait
+const BatchStrategyName = "batch"
+
+// DefaultBatchWindow is the default time between two batches of the batch
+// strategy
+const DefaultBatchWindow = time.Second
+
+// BatchStrategy is implemented by the strategies that match the orders and
+// couriers in batches rather than one by one. Besides being told when an order
+// is prepared and when a courier arrives (to hand over the pairs it has matched
+// already), it is told about the orders being cooked and the couriers on the
+// way, and the order managers ask it for a batch of pairs at every window
+type BatchStrategy interface {
+	Strategy
+	// GetWindow gets the time between two batches
+	GetWindow() time.Duration
+	// OrderCooking adds an order that has started cooking
+	OrderCooking(order *DispatchedOrder)
+	// CourierDispatched adds a courier that has set off to the kitchen
+	CourierDispatched(courier *DispatchedCourier)
+	// Assign matches the orders and couriers at the end of a window, and
+	// returns the pairs of a prepared order and an arrived courier (at the same
+	// index) to pick up right away; the other pairs are picked up once both
+	// are at the kitchen, unless a later batch matches them otherwise
+	Assign(now time.Time) (orders []*DispatchedOrder, couriers []*DispatchedCourier)
+}
+
+// batchOrder is an order of the batch strategy, and the courier matched with it
+type batchOrder struct {
+	order   *DispatchedOrder
+	ready   bool
+	courier *batchCourier
+}
+
+// batchCourier is a courier of the batch strategy, and the order matched with it
+type batchCourier struct {
+	courier *DispatchedCourier
+	arrived bool
+	order   *batchOrder
+}
+
+// batchStrategy collects the orders being cooked or waiting on a shelf, and the
+// couriers on the way or waiting at the kitchen, and matches them every window
+// so that the combined wait of the food and the couriers is the lowest (a
+// minimum-cost assignment solved with the Hungarian algorithm). Unlike FIFO,
+// a prepared order may wait for a courier that is about to arrive rather than
+// go to one that has been waiting, and vice versa
+type batchStrategy struct {
+	window          time.Duration
+	orders          *list.List // in the order they started cooking (*batchOrder)
+	couriers        *list.List // in the order they were dispatched (*batchCourier)
+	orderElements   map[*DispatchedOrder]*list.Element
+	courierElements map[*DispatchedCourier]*list.Element
+}
+
+func (b *batchStrategy) Init() {
+	b.orders.Init()
+	b.couriers.Init()
+	b.orderElements = map[*DispatchedOrder]*list.Element{}
+	b.courierElements = map[*DispatchedCourier]*list.Element{}
+}
+
+func (b *batchStrategy) GetWindow() time.Duration {
+	return b.window
+}
+
+// getOrder <private> gets the order, which is added if it is not known yet
+func (b *batchStrategy) getOrder(order *DispatchedOrder) *batchOrder {
+	if element, ok := b.orderElements[order]; ok {
+		return element.Value.(*batchOrder)
+	}
+	entry := &batchOrder{order: order}
+	b.orderElements[order] = b.orders.PushBack(entry)
+	return entry
+}
+
+// getCourier <private> gets the courier, which is added if it is not known yet
+func (b *batchStrategy) getCourier(courier *DispatchedCourier) *batchCourier {
+	if element, ok := b.courierElements[courier]; ok {
+		return element.Value.(*batchCourier)
+	}
+	entry := &batchCourier{courier: courier}
+	b.courierElements[courier] = b.couriers.PushBack(entry)
+	return entry
+}
+
+// removeOrder <private> removes the order, and unmatches its courier
+func (b *batchStrategy) removeOrder(entry *batchOrder) {
+	if entry.courier != nil {
+		entry.courier.order = nil
+	}
+	b.orders.Remove(b.orderElements[entry.order])
+	delete(b.orderElements, entry.order)
+}
+
+// removeCourier <private> removes the courier, and unmatches its order
+func (b *batchStrategy) removeCourier(entry *batchCourier) {
+	if entry.order != nil {
+		entry.order.courier = nil
+	}
+	b.couriers.Remove(b.courierElements[entry.courier])
+	delete(b.courierElements, entry.courier)
+}
+
+func (b *batchStrategy) OrderCooking(order *DispatchedOrder) {
+	b.getOrder(order)
+}
+
+func (b *batchStrategy) CourierDispatched(courier *DispatchedCourier) {
+	b.getCourier(courier)
+}
+
+func (b *batchStrategy) OrderReady(order *DispatchedOrder) *DispatchedCourier {
+	entry := b.getOrder(order)
+	entry.ready = true
+	courier := entry.courier
+	if courier == nil || !courier.arrived { // waits for the next batch, or its courier
+		return nil
+	}
+	b.removeOrder(entry)
+	b.removeCourier(courier)
+	return courier.courier
+}
+
+func (b *batchStrategy) CourierArrived(courier *DispatchedCourier) (*DispatchedOrder, bool) {
+	entry := b.getCourier(courier)
+	entry.arrived = true
+	order := entry.order
+	if order == nil || !order.ready { // waits for the next batch, or its order
+		return nil, true
+	}
+	b.removeOrder(order)
+	b.removeCourier(entry)
+	return order.order, false
+}
+
+func (b *batchStrategy) RemoveOrder(order *DispatchedOrder) {
+	if element, ok := b.orderElements[order]; ok {
+		b.removeOrder(element.Value.(*batchOrder))
+	}
+}
+
+func (b *batchStrategy) RemoveCourier(courier *DispatchedCourier) bool {
+	element, ok := b.courierElements[courier]
+	if !ok {
+		return false
+	}
+	entry := element.Value.(*batchCourier)
+	b.removeCourier(entry)
+	return entry.arrived
+}
+
+// getReadyTime <private> gets when the order is (expected to be) prepared, as
+// of now
+func (b *batchStrategy) getReadyTime(entry *batchOrder, now time.Time) time.Time {
+	if entry.ready {
+		return entry.order.FinishTime
+	}
+	return latest(entry.order.CookStartTime.Add(time.Duration(entry.order.Order.PrepTime)*time.Second), now)
+}
+
+// getArrivalTime <private> gets when the courier arrives (or is expected to),
+// as of now; a courier who is late is expected right away
+func (b *batchStrategy) getArrivalTime(entry *batchCourier, now time.Time) time.Time {
+	if entry.arrived {
+		return entry.courier.ArrivedTime
+	}
+	return latest(entry.courier.DispatchedTime.Add(time.Duration(entry.courier.Courier.TravelTime)*time.Second), now)
+}
+
+func (b *batchStrategy) Assign(now time.Time) ([]*DispatchedOrder, []*DispatchedCourier) {
+	var orders []*batchOrder
+	var couriers []*batchCourier
+	var readyTimes, arrivalTimes []time.Time
+	for element := b.orders.Front(); element != nil; element = element.Next() {
+		entry := element.Value.(*batchOrder)
+		orders = append(orders, entry)
+		readyTimes = append(readyTimes, b.getReadyTime(entry, now))
+	}
+	for element := b.couriers.Front(); element != nil; element = element.Next() {
+		entry := element.Value.(*batchCourier)
+		couriers = append(couriers, entry)
+		arrivalTimes = append(arrivalTimes, b.getArrivalTime(entry, now))
+	}
+	if len(orders) == 0 || len(couriers) == 0 {
+		return nil, nil
+	}
+
+	// The cost of a pair is the time the food and the courier wait for each
+	// other. The orders or couriers in excess are left for a later batch (with
+	// a dummy counterpart), at a penalty higher than any matching of the others
+	// so that as many as possible are matched. On top of it, the ones left wait
+	// until the next batch at least; that wait counts twice, so that the orders
+	// and couriers that have waited the longest are matched first
+	next := now.Add(b.window)
+	size := len(orders) + len(couriers)
+	cost := make([][]float64, size)
+	penalty := 1.0
+	for i := range cost {
+		cost[i] = make([]float64, size)
+		for j := range cost[i] {
+			if i < len(orders) && j < len(couriers) { // picked up by the later of the two
+				pickUp := latest(latest(readyTimes[i], arrivalTimes[j]), now)
+				cost[i][j] = (pickUp.Sub(readyTimes[i]) + pickUp.Sub(arrivalTimes[j])).Seconds()
+				penalty += cost[i][j]
+			}
+		}
+	}
+	for i := range cost {
+		for j := range cost[i] {
+			switch {
+			case i < len(orders) && j >= len(couriers): // the order is left for a later batch
+				cost[i][j] = penalty + 2*latest(next, readyTimes[i]).Sub(readyTimes[i]).Seconds()
+			case i >= len(orders) && j < len(couriers): // the courier is left for a later batch
+				cost[i][j] = penalty + 2*latest(next, arrivalTimes[j]).Sub(arrivalTimes[j]).Seconds()
+			}
+		}
+	}
+
+	for _, entry := range orders { // the pairs of the previous batch are reconsidered
+		entry.courier = nil
+	}
+	for _, entry := range couriers {
+		entry.order = nil
+	}
+	var pickedUpOrders []*DispatchedOrder
+	var pickedUpCouriers []*DispatchedCourier
+	for i, j := range solveAssignment(cost) {
+		if i >= len(orders) || j >= len(couriers) {
+			continue
+		}
+		order, courier := orders[i], couriers[j]
+		if order.ready && courier.arrived {
+			b.removeOrder(order)
+			b.removeCourier(courier)
+			pickedUpOrders = append(pickedUpOrders, order.order)
+			pickedUpCouriers = append(pickedUpCouriers, courier.courier)
+		} else {
+			order.courier, courier.order = courier, order
+		}
+	}
+	return pickedUpOrders, pickedUpCouriers
+}
+
+// latest gets the later of two times
+func latest(a time.Time, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// NewBatchStrategy constructs a strategy that matches the orders and couriers
+// in batches every window (DefaultBatchWindow if zero or less), with the
+// minimum combined wait of the food and the couriers
+func NewBatchStrategy(window time.Duration) Strategy {
+	return getBatchStrategy(window)
+}
+
+func getBatchStrategy(window time.Duration) *batchStrategy {
+	if window <= 0 {
+		window = DefaultBatchWindow
+	}
+	return &batchStrategy{
+		window:          window,
+		orders:          list.New(),
+		couriers:        list.New(),
+		orderElements:   map[*DispatchedOrder]*list.Element{},
+		courierElements: map[*DispatchedCourier]*list.Element{},
+	}
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"wonsoh.private/cloudkitchens/clock"
+	"wonsoh.private/cloudkitchens/resource"
+)
+
+type BatchStrategyTestSuite struct {
+	suite.Suite
+	now time.Time
+}
+
+func (b *BatchStrategyTestSuite) SetupTest() {
+	b.now = time.Now()
+}
+
+// getOrder gets an order that started cooking now
+func (b *BatchStrategyTestSuite) getOrder(id string, prepTime int) *DispatchedOrder {
+	order := getDispatchedOrder(nil, clock.GetSimulatedClock(b.now), &resource.Order{
+		ID:       id,
+		Name:     "Food " + id,
+		PrepTime: prepTime,
+	})
+	order.CookStartTime = b.now
+	return order
+}
+
+// getCourier gets a courier that set off to the kitchen now
+func (b *BatchStrategyTestSuite) getCourier(id string, travelTime int) *DispatchedCourier {
+	return getDispatchedCourier(nil, clock.GetSimulatedClock(b.now), resource.NewCourier(id, travelTime))
+}
+
+func (b *BatchStrategyTestSuite) TestAssign() {
+	// Food 1 is ready at 2s, and food 2 at 9s; courier 1 arrives at 1s, and
+	// courier 2 at 10s. Matching 1 with 1 and 2 with 2 makes them wait 2 seconds
+	// in all, instead of 16 seconds the other way around
+	strategy := getBatchStrategy(time.Second)
+	order1, order2 := b.getOrder("1", 2), b.getOrder("2", 9)
+	courier1, courier2 := b.getCourier("1", 1), b.getCourier("2", 10)
+	strategy.OrderCooking(order1)
+	strategy.OrderCooking(order2)
+	strategy.CourierDispatched(courier2)
+	strategy.CourierDispatched(courier1)
+	orders, couriers := strategy.Assign(b.now)
+	b.Empty(orders) // no pair is at the kitchen yet
+	b.Empty(couriers)
+
+	courier1.ArrivedTime = b.now.Add(time.Second)
+	order, wait := strategy.CourierArrived(courier1)
+	b.Nil(order)
+	b.True(wait) // for food 1
+	order1.FinishTime = b.now.Add(2 * time.Second)
+	b.Equal(courier1, strategy.OrderReady(order1))
+
+	order2.FinishTime = b.now.Add(9 * time.Second)
+	b.Nil(strategy.OrderReady(order2)) // waits for courier 2
+	courier2.ArrivedTime = b.now.Add(10 * time.Second)
+	order, wait = strategy.CourierArrived(courier2)
+	b.Equal(order2, order)
+	b.False(wait)
+}
+
+func (b *BatchStrategyTestSuite) TestAssignAtTheKitchen() {
+	// the food and the courier waiting at the kitchen are picked up right away
+	// at the end of the window; the oldest food goes first
+	strategy := getBatchStrategy(time.Second)
+	order1, order2, courier := b.getOrder("1", 0), b.getOrder("2", 0), b.getCourier("1", 0)
+	order1.FinishTime = b.now
+	b.Nil(strategy.OrderReady(order1))
+	order2.FinishTime = b.now.Add(time.Second)
+	b.Nil(strategy.OrderReady(order2))
+	courier.ArrivedTime = b.now.Add(time.Second)
+	order, wait := strategy.CourierArrived(courier)
+	b.Nil(order) // the pairs are only matched at the end of the window
+	b.True(wait)
+
+	orders, couriers := strategy.Assign(b.now.Add(time.Second))
+	b.Equal([]*DispatchedOrder{order1}, orders)
+	b.Equal([]*DispatchedCourier{courier}, couriers)
+	orders, _ = strategy.Assign(b.now.Add(2 * time.Second))
+	b.Empty(orders) // food 2 is left waiting for a courier
+}
+
+func (b *BatchStrategyTestSuite) TestRemove() {
+	strategy := getBatchStrategy(0)
+	b.Equal(DefaultBatchWindow, strategy.GetWindow())
+	order1, order2 := b.getOrder("1", 2), b.getOrder("2", 4)
+	courier1, courier2 := b.getCourier("1", 2), b.getCourier("2", 4)
+	strategy.OrderCooking(order1)
+	strategy.OrderCooking(order2)
+	strategy.CourierDispatched(courier1)
+	strategy.CourierDispatched(courier2)
+	strategy.Assign(b.now)
+
+	b.False(strategy.RemoveCourier(courier1)) // on the way; food 1 is no longer matched
+	order1.FinishTime = b.now.Add(2 * time.Second)
+	b.Nil(strategy.OrderReady(order1))
+	strategy.RemoveOrder(order2) // courier 2 is no longer matched
+	courier2.ArrivedTime = b.now.Add(4 * time.Second)
+	order, wait := strategy.CourierArrived(courier2)
+	b.Nil(order)
+	b.True(wait)
+	b.True(strategy.RemoveCourier(courier2)) // was waiting
+	b.False(strategy.RemoveCourier(courier2))
+
+	strategy.Init()
+	orders, _ := strategy.Assign(b.now.Add(5 * time.Second))
+	b.Empty(orders) // nothing is left
+}
+
+func TestBatchStrategyTestSuite(t *testing.T) {
+	suite.Run(t, new(BatchStrategyTestSuite))
+}
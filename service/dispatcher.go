@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"time"
+
+	"wonsoh.private/cloudkitchens/clock"
+	"wonsoh.private/cloudkitchens/resource"
+)
+
+const (
+	// DefaultOrdersPerSecond is the default ingestion rate of orders
+	DefaultOrdersPerSecond = 2
+)
+
+// DispatchRate describes how fast orders are dispatched to an order manager
+type DispatchRate struct {
+	// OrdersPerSecond is the average number of orders dispatched per second;
+	// zero (or less) dispatches all the orders at once
+	OrdersPerSecond float64
+	// Jitter randomly stretches or shrinks each interval between dispatches by
+	// up to this fraction of the interval (between 0 and 1)
+	Jitter float64
+	// Burst is the number of orders dispatched together at every interval;
+	// the interval grows accordingly so that the average rate is kept
+	Burst int
+}
+
+// OrderSource yields the orders to dispatch one at a time, e.g. as they are
+// decoded from a large file (see reader.OrderStream)
+type OrderSource interface {
+	// Next gets the next order; returns io.EOF once there are no more
+	Next() (*resource.Order, error)
+}
+
+// Dispatcher feeds orders into an order manager over time
+type Dispatcher interface {
+	Dispatch(ctx context.Context, orders []*resource.Order) error
+	// DispatchStream dispatches the orders of the source as Dispatch does,
+	// fetching each one just before it is due; returns the error of the source
+	// if it fails
+	DispatchStream(ctx context.Context, source OrderSource) error
+}
+
+// sliceSource yields the orders of a slice
+type sliceSource struct {
+	orders []*resource.Order
+}
+
+func (s *sliceSource) Next() (*resource.Order, error) {
+	if len(s.orders) == 0 {
+		return nil, io.EOF
+	}
+	order := s.orders[0]
+	s.orders = s.orders[1:]
+	return order, nil
+}
+
+type pacedDispatcher struct {
+	manager OrderManager
+	clock   clock.Clock
+	random  *rand.Rand
+	rate    DispatchRate
+}
+
+// interval <private> gets the time to wait before dispatching the next burst
+func (p *pacedDispatcher) interval() time.Duration {
+	seconds := float64(p.burst()) / p.rate.OrdersPerSecond
+	if p.rate.Jitter > 0 {
+		seconds *= 1 + p.rate.Jitter*(2*p.random.Float64()-1)
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+func (p *pacedDispatcher) burst() int {
+	if p.rate.Burst < 1 {
+		return 1
+	}
+	return p.rate.Burst
+}
+
+// Dispatch dispatches the orders to the order manager at the configured rate
+// (or at their scheduled times, for the orders that have one); it returns
+// once all the orders are dispatched, without waiting for them, or with the
+// error of the context as soon as it is done
+func (p *pacedDispatcher) Dispatch(ctx context.Context, orders []*resource.Order) error {
+	return p.DispatchStream(ctx, &sliceSource{orders: orders})
+}
+
+func (p *pacedDispatcher) DispatchStream(ctx context.Context, source OrderSource) error {
+	start := p.clock.Now()
+	for i := 0; ; i++ {
+		order, err := source.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		// a nil order goes straight to the order manager, which rejects it
+		if order != nil && order.ScheduledTime > 0 { // at its scheduled time, regardless of the rate
+			due := start.Add(time.Duration(order.ScheduledTime * float64(time.Second)))
+			if wait := due.Sub(p.clock.Now()); wait > 0 {
+				if e := p.clock.SleepContext(ctx, wait); e != nil {
+					return e
+				}
+			}
+		} else if i > 0 && i%p.burst() == 0 && p.rate.OrdersPerSecond > 0 {
+			if e := p.clock.SleepContext(ctx, p.interval()); e != nil {
+				return e
+			}
+		}
+		if e := p.manager.DispatchOrder(ctx, order); e != nil {
+			return e
+		}
+	}
+}
+
+// GetDispatcher constructs a new Dispatcher that dispatches orders to the
+// order manager at the given rate, measured with the given clock. The random
+// number generator is used for the jitter only
+func GetDispatcher(
+	manager OrderManager,
+	clk clock.Clock,
+	random *rand.Rand,
+	rate DispatchRate,
+) Dispatcher {
+	return &pacedDispatcher{
+		manager: manager,
+		clock:   clk,
+		random:  random,
+		rate:    rate,
+	}
+}
@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"wonsoh.private/cloudkitchens/clock"
+	"wonsoh.private/cloudkitchens/resource"
+)
+
+type DispatcherTestSuite struct {
+	suite.Suite
+	start  time.Time
+	clock  clock.Clock
+	orders []*resource.Order
+}
+
+// recordingOrderManager records the time each order is dispatched at
+type recordingOrderManager struct {
+	*mockOrderManager
+	clock        clock.Clock
+	dispatchedAt []time.Duration
+	start        time.Time
+}
+
+func (r *recordingOrderManager) DispatchOrder(ctx context.Context, order *resource.Order) error {
+	if e := r.mockOrderManager.DispatchOrder(ctx, order); e != nil {
+		return e
+	}
+	r.dispatchedAt = append(r.dispatchedAt, r.clock.Now().Sub(r.start))
+	return nil
+}
+
+func (d *DispatcherTestSuite) SetupTest() {
+	d.start = time.Now()
+	d.clock = clock.GetSimulatedClock(d.start)
+	d.orders = make([]*resource.Order, 5)
+	for i := range d.orders {
+		d.orders[i] = &resource.Order{ID: fmt.Sprintf("%d", i), PrepTime: 1}
+	}
+}
+
+func (d *DispatcherTestSuite) getManager() *recordingOrderManager {
+	return &recordingOrderManager{
+		mockOrderManager: &mockOrderManager{},
+		clock:            d.clock,
+		start:            d.start,
+	}
+}
+
+func (d *DispatcherTestSuite) TestSteadyRate() {
+	manager := d.getManager()
+	dispatcher := GetDispatcher(manager, d.clock, nil, DispatchRate{OrdersPerSecond: 2})
+	d.NoError(dispatcher.Dispatch(context.Background(), d.orders))
+	d.Equal([]time.Duration{
+		0,
+		500 * time.Millisecond,
+		1000 * time.Millisecond,
+		1500 * time.Millisecond,
+		2000 * time.Millisecond,
+	}, manager.dispatchedAt)
+}
+
+func (d *DispatcherTestSuite) TestAllAtOnce() {
+	manager := d.getManager()
+	dispatcher := GetDispatcher(manager, d.clock, nil, DispatchRate{})
+	d.NoError(dispatcher.Dispatch(context.Background(), d.orders))
+	d.Equal(make([]time.Duration, len(d.orders)), manager.dispatchedAt)
+}
+
+func (d *DispatcherTestSuite) TestBurst() {
+	manager := d.getManager()
+	dispatcher := GetDispatcher(manager, d.clock, nil, DispatchRate{OrdersPerSecond: 2, Burst: 2})
+	d.NoError(dispatcher.Dispatch(context.Background(), d.orders))
+	d.Equal([]time.Duration{
+		0,
+		0,
+		time.Second,
+		time.Second,
+		2 * time.Second,
+	}, manager.dispatchedAt)
+}
+
+func (d *DispatcherTestSuite) TestJitter() {
+	manager := d.getManager()
+	dispatcher := GetDispatcher(
+		manager,
+		d.clock,
+		resource.GetFixedSeedRandomNumberGenerator(),
+		DispatchRate{OrdersPerSecond: 1, Jitter: 0.5},
+	)
+	d.NoError(dispatcher.Dispatch(context.Background(), d.orders))
+	d.Len(manager.dispatchedAt, len(d.orders))
+	for i := 1; i < len(manager.dispatchedAt); i++ {
+		interval := manager.dispatchedAt[i] - manager.dispatchedAt[i-1]
+		d.GreaterOrEqual(int64(interval), int64(500*time.Millisecond))
+		d.LessOrEqual(int64(interval), int64(1500*time.Millisecond))
+	}
+}
+
+func (d *DispatcherTestSuite) TestDispatchError() {
+	manager := d.getManager()
+	manager.dispatchOrderError = true
+	dispatcher := GetDispatcher(manager, d.clock, nil, DispatchRate{OrdersPerSecond: 2})
+	d.Error(dispatcher.Dispatch(context.Background(), d.orders))
+	d.Empty(manager.dispatchedAt)
+	d.NoError(GetDispatcher(manager, d.clock, nil, DispatchRate{}).Dispatch(context.Background(), nil))
+}
+
+func (d *DispatcherTestSuite) TestDispatchNilOrder() {
+	manager := NewFIFOOrderManager(resource.GetFixedSeedRandomNumberGenerator(), d.clock, Config{})
+	dispatcher := GetDispatcher(manager, d.clock, nil, DispatchRate{})
+	err := dispatcher.Dispatch(context.Background(), []*resource.Order{d.orders[0], nil, d.orders[1]})
+	d.True(errors.Is(err, ErrNilOrder))
+	d.Equal([]*resource.Order{d.orders[0]}, manager.GetUndeliveredOrders()) // stopped at the nil order
+	d.NoError(manager.Wait(context.Background()))
+}
+
+func (d *DispatcherTestSuite) TestDispatchCancelled() {
+	manager := d.getManager()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	dispatcher := GetDispatcher(manager, d.clock, nil, DispatchRate{OrdersPerSecond: 2})
+	d.Equal(context.Canceled, dispatcher.Dispatch(ctx, d.orders))
+	d.Len(manager.dispatchedAt, 1) // the mock dispatches regardless; the dispatcher stops sleeping
+	d.Equal(d.start, d.clock.Now())
+}
+
+func (d *DispatcherTestSuite) TestScheduledTime() {
+	// 1 and 3 are dispatched at their scheduled times (3 right away, since it
+	// is past due once 2 is dispatched), and the others at the rate
+	d.orders[1].ScheduledTime = 2.5
+	d.orders[3].ScheduledTime = 2
+	manager := d.getManager()
+	dispatcher := GetDispatcher(manager, d.clock, nil, DispatchRate{OrdersPerSecond: 2})
+	d.NoError(dispatcher.Dispatch(context.Background(), d.orders))
+	d.Equal([]time.Duration{
+		0,
+		2500 * time.Millisecond,
+		3000 * time.Millisecond,
+		3000 * time.Millisecond,
+		3500 * time.Millisecond,
+	}, manager.dispatchedAt)
+}
+
+// failingSource yields its orders, then fails
+type failingSource struct {
+	orders []*resource.Order
+}
+
+func (f *failingSource) Next() (*resource.Order, error) {
+	if len(f.orders) == 0 {
+		return nil, errors.New("malformed order")
+	}
+	order := f.orders[0]
+	f.orders = f.orders[1:]
+	return order, nil
+}
+
+func (d *DispatcherTestSuite) TestDispatchStream() {
+	manager := d.getManager()
+	dispatcher := GetDispatcher(manager, d.clock, nil, DispatchRate{OrdersPerSecond: 2})
+	d.NoError(dispatcher.DispatchStream(context.Background(), &sliceSource{orders: d.orders}))
+	d.Len(manager.dispatchedAt, len(d.orders))
+
+	// the orders before the failure are dispatched on time
+	manager = d.getManager()
+	dispatcher = GetDispatcher(manager, d.clock, nil, DispatchRate{OrdersPerSecond: 2})
+	d.EqualError(dispatcher.DispatchStream(context.Background(), &failingSource{orders: d.orders[:2]}), "malformed order")
+	d.Len(manager.dispatchedAt, 2)
+	d.Equal(500*time.Millisecond, manager.dispatchedAt[1]-manager.dispatchedAt[0])
+}
+
+func TestDispatcherTestSuite(t *testing.T) {
+	suite.Run(t, new(DispatcherTestSuite))
+}
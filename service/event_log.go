@@ -0,0 +1,145 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventType is the type of an event logged by the order managers
+type EventType string
+
+const (
+	// EventOrderDispatched is logged when an order is dispatched
+	EventOrderDispatched EventType = "OrderDispatched"
+	// EventOrderReceived is logged when a cook station starts cooking an order
+	EventOrderReceived EventType = "OrderReceived"
+	// EventOrderPrepared is logged when the food of an order is ready
+	EventOrderPrepared EventType = "OrderPrepared"
+	// EventOrderDiscarded is logged when an order is discarded from the shelves
+	EventOrderDiscarded EventType = "OrderDiscarded"
+	// EventOrderWasted is logged when an order goes stale on the shelves
+	EventOrderWasted EventType = "OrderWasted"
+	// EventOrderCancelled is logged when an order is cancelled, along with the
+	// courier recalled from it
+	EventOrderCancelled EventType = "OrderCancelled"
+	// EventCourierDispatched is logged when a courier sets off to the kitchen
+	EventCourierDispatched EventType = "CourierDispatched"
+	// EventCourierReplaced is logged when a courier has not arrived at the
+	// kitchen in time, and a new one is dispatched instead
+	EventCourierReplaced EventType = "CourierReplaced"
+	// EventCourierArrived is logged when a courier arrives at the kitchen
+	EventCourierArrived EventType = "CourierArrived"
+	// EventCourierReturned is logged when a courier of a limited fleet comes
+	// back after delivering an order
+	EventCourierReturned EventType = "CourierReturned"
+	// EventPickedUp is logged when a courier picks up the food of an order
+	EventPickedUp EventType = "PickedUp"
+)
+
+// Event is an event logged by the order managers. All the types of events
+// share the same schema; the fields that do not apply to a type are empty
+type Event struct {
+	// Sequence numbers the events of an order manager in the order they
+	// happened, from 1
+	Sequence int64     `json:"sequence"`
+	Type     EventType `json:"type"`
+	// Time is the simulated time of the event
+	Time      time.Time `json:"time"`
+	OrderID   string    `json:"orderId"`
+	OrderName string    `json:"orderName"`
+	// PrepTime is the preparation time of the order in seconds
+	PrepTime int `json:"prepTime"`
+	// Shelf is the shelf the order was on
+	Shelf     string `json:"shelf"`
+	CourierID string `json:"courierId"`
+	// TravelTime is the travel time of the courier in seconds
+	TravelTime int `json:"travelTime"`
+	// FoodWaitTime is the time in ms the food waited for the courier (PickedUp only)
+	FoodWaitTime int `json:"foodWaitTime"`
+	// CourierWaitTime is the time in ms the courier waited for the food (PickedUp only)
+	CourierWaitTime int `json:"courierWaitTime"`
+}
+
+// EventLogFormat is the format the events are logged in
+type EventLogFormat string
+
+const (
+	// EventLogText logs an event per human-readable line
+	EventLogText EventLogFormat = "text"
+	// EventLogJSON logs an event per line of JSON (NDJSON)
+	EventLogJSON EventLogFormat = "json"
+)
+
+// ParseEventLogFormat parses the name of an event log format
+func ParseEventLogFormat(name string) (EventLogFormat, error) {
+	switch format := EventLogFormat(name); format {
+	case EventLogText, EventLogJSON:
+		return format, nil
+	}
+	return "", fmt.Errorf("unknown event log format %q", name)
+}
+
+// EventLogger logs the events of the order managers. It is thread-safe
+type EventLogger interface {
+	Log(event Event)
+}
+
+// eventLogger writes the events in a format
+type eventLogger struct {
+	mutex  *sync.Mutex
+	w      io.Writer
+	format func(event Event) []byte
+}
+
+func (e *eventLogger) Log(event Event) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.w.Write(e.format(event))
+}
+
+// formatText <private> formats an event as a human-readable line with the
+// fields that apply to its type
+func formatText(event Event) []byte {
+	builder := &strings.Builder{}
+	fmt.Fprintf(builder, "%s #%d %s", event.Time.Format(time.StampMilli), event.Sequence, event.Type)
+	if event.OrderID != "" {
+		fmt.Fprintf(builder, "\torder: %s", event.OrderID)
+	}
+	if event.OrderName != "" {
+		fmt.Fprintf(builder, " (%s, prep time: %ds)", event.OrderName, event.PrepTime)
+	}
+	if event.Shelf != "" {
+		fmt.Fprintf(builder, "\tshelf: %s", event.Shelf)
+	}
+	if event.CourierID != "" {
+		fmt.Fprintf(builder, "\tcourier: %s (travel time: %ds)", event.CourierID, event.TravelTime)
+	}
+	if event.Type == EventPickedUp {
+		fmt.Fprintf(builder, "\tfood waited: %d ms\tcourier waited: %d ms", event.FoodWaitTime, event.CourierWaitTime)
+	}
+	builder.WriteByte('\n')
+	return []byte(builder.String())
+}
+
+// formatJSON <private> formats an event as a line of JSON
+func formatJSON(event Event) []byte {
+	line, _ := json.Marshal(event) // an event always marshals
+	return append(line, '\n')
+}
+
+// NewEventLogger constructs an event logger that writes to w in the given format
+func NewEventLogger(w io.Writer, format EventLogFormat) EventLogger {
+	logger := &eventLogger{
+		mutex:  &sync.Mutex{},
+		w:      w,
+		format: formatText,
+	}
+	if format == EventLogJSON {
+		logger.format = formatJSON
+	}
+	return logger
+}
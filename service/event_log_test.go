@@ -0,0 +1,102 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type EventLogTestSuite struct {
+	suite.Suite
+}
+
+// recordingEventLogger keeps the logged events
+type recordingEventLogger struct {
+	mutex  sync.Mutex
+	events []Event
+}
+
+func (r *recordingEventLogger) Log(event Event) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.events = append(r.events, event)
+}
+
+// countTypes counts the logged events of each type
+func (r *recordingEventLogger) countTypes() map[EventType]int {
+	counts := map[EventType]int{}
+	for _, event := range r.events {
+		counts[event.Type]++
+	}
+	return counts
+}
+
+func (e *EventLogTestSuite) TestParseEventLogFormat() {
+	format, err := ParseEventLogFormat("json")
+	e.NoError(err)
+	e.Equal(EventLogJSON, format)
+	_, err = ParseEventLogFormat("xml")
+	e.Error(err)
+}
+
+func (e *EventLogTestSuite) TestJSON() {
+	buffer := &bytes.Buffer{}
+	logger := NewEventLogger(buffer, EventLogJSON)
+	at := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	logger.Log(Event{Sequence: 1, Type: EventOrderDispatched, Time: at, OrderID: "1", OrderName: "Food 1", PrepTime: 2})
+	logger.Log(Event{Sequence: 2, Type: EventPickedUp, Time: at, OrderID: "1", CourierID: "c1", FoodWaitTime: 2000})
+
+	scanner := bufio.NewScanner(buffer)
+	var sequence int64
+	for scanner.Scan() {
+		var fields map[string]interface{}
+		e.NoError(json.Unmarshal(scanner.Bytes(), &fields))
+		e.Len(fields, 11) // the same schema for every type
+		var event Event
+		e.NoError(json.Unmarshal(scanner.Bytes(), &event))
+		sequence++
+		e.Equal(sequence, event.Sequence)
+		e.Equal(at, event.Time)
+	}
+	e.EqualValues(2, sequence)
+}
+
+func (e *EventLogTestSuite) TestText() {
+	buffer := &bytes.Buffer{}
+	logger := NewEventLogger(buffer, EventLogText)
+	logger.Log(Event{Sequence: 1, Type: EventCourierDispatched, OrderID: "1", CourierID: "c1", TravelTime: 3})
+	logger.Log(Event{Sequence: 2, Type: EventPickedUp, OrderID: "1", OrderName: "Food 1", CourierID: "c1", CourierWaitTime: 5000})
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	e.Len(lines, 2)
+	e.Contains(lines[0], "#1 CourierDispatched")
+	e.Contains(lines[0], "courier: c1 (travel time: 3s)")
+	e.NotContains(lines[0], "waited")
+	e.Contains(lines[1], "#2 PickedUp")
+	e.Contains(lines[1], "courier waited: 5000 ms")
+}
+
+func (e *EventLogTestSuite) TestOrderManagerEvents() {
+	// both engines log the same events
+	runOnEngines(&e.Suite, engineRun{}, func(name string, _ OrderManager, logger *recordingEventLogger) {
+		e.Equal(map[EventType]int{
+			EventOrderDispatched:   4,
+			EventOrderReceived:     4,
+			EventOrderPrepared:     4,
+			EventCourierDispatched: 4,
+			EventCourierArrived:    4,
+			EventPickedUp:          4,
+		}, logger.countTypes(), name)
+		e.Equal(EventOrderDispatched, logger.events[0].Type, name)
+		e.Equal(EventPickedUp, logger.events[len(logger.events)-1].Type, name)
+	})
+}
+
+func TestEventLogTestSuite(t *testing.T) {
+	suite.Run(t, new(EventLogTestSuite))
+}
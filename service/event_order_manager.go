@@ -0,0 +1,339 @@
+package service
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"wonsoh.private/cloudkitchens/clock"
+	"wonsoh.private/cloudkitchens/resource"
+)
+
+// eventKind is the kind of an event in the discrete-event simulation
+type eventKind int
+
+const (
+	orderReadyEvent eventKind = iota
+	courierArrivedEvent
+	courierReturnedEvent
+	courierTimeoutEvent
+	batchEvent
+	stackTimeoutEvent
+)
+
+// simulationEvent is an event scheduled to happen at a given time
+type simulationEvent struct {
+	at       time.Time
+	sequence int
+	kind     eventKind
+	order    *DispatchedOrder
+	courier  *DispatchedCourier
+}
+
+// eventQueue is a min-heap of events ordered by time; events happening at the
+// same time are ordered by the time they were scheduled
+type eventQueue []*simulationEvent
+
+func (e eventQueue) Len() int {
+	return len(e)
+}
+
+func (e eventQueue) Less(i, j int) bool {
+	if e[i].at.Equal(e[j].at) {
+		return e[i].sequence < e[j].sequence
+	}
+	return e[i].at.Before(e[j].at)
+}
+
+func (e eventQueue) Swap(i, j int) {
+	e[i], e[j] = e[j], e[i]
+}
+
+func (e *eventQueue) Push(x interface{}) {
+	*e = append(*e, x.(*simulationEvent))
+}
+
+func (e *eventQueue) Pop() interface{} {
+	old := *e
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	*e = old[:n-1]
+	return item
+}
+
+// eventOrderManager is a single-threaded discrete-event simulation of an order
+// manager. Instead of running a goroutine per order and courier, it keeps the
+// upcoming events in a priority queue and processes them in the order of
+// time, so the results are deterministic and no time is spent sleeping.
+//
+// The clock is only used to timestamp the dispatched orders; DispatchOrder and
+// Wait must be called from the same goroutine
+type eventOrderManager struct {
+	*orderManagerBase
+	events   *eventQueue
+	sequence int
+}
+
+// Init initializes the event order manager instance
+func (e *eventOrderManager) Init(random *rand.Rand) {
+	e.orderManagerBase.Init(random)
+	e.events = &eventQueue{}
+	e.sequence = 0
+}
+
+// schedule <private> adds an event to the event queue
+func (e *eventOrderManager) schedule(
+	at time.Time,
+	kind eventKind,
+	order *DispatchedOrder,
+	courier *DispatchedCourier,
+) {
+	e.sequence++
+	heap.Push(e.events, &simulationEvent{
+		at:       at,
+		sequence: e.sequence,
+		kind:     kind,
+		order:    order,
+		courier:  courier,
+	})
+}
+
+// scheduleOrderReady <private> schedules when an order that has started
+// cooking gets prepared
+func (e *eventOrderManager) scheduleOrderReady(order *DispatchedOrder) {
+	e.schedule(
+		order.CookStartTime.Add(time.Duration(order.Order.PrepTime)*time.Second),
+		orderReadyEvent,
+		order,
+		nil,
+	)
+}
+
+// scheduleTrip <private> schedules when a dispatched courier arrives (unless it
+// never shows up), and when it times out if it is not going to arrive in time
+func (e *eventOrderManager) scheduleTrip(courier *DispatchedCourier) {
+	if !courier.Courier.NoShow {
+		e.schedule(
+			courier.DispatchedTime.Add(time.Duration(courier.Courier.TravelTime)*time.Second),
+			courierArrivedEvent,
+			nil,
+			courier,
+		)
+	}
+	if e.isLate(courier) {
+		e.schedule(courier.DispatchedTime.Add(e.getNoShowTimeout()), courierTimeoutEvent, nil, courier)
+	}
+}
+
+// DispatchOrder dispatches order to the order manager by scheduling when the
+// order gets prepared and when its courier arrives. Events up until now are
+// processed first, so that the order sees the kitchen as it is now
+func (e *eventOrderManager) DispatchOrder(ctx context.Context, order *resource.Order) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	now := e.clock.Now()
+	e.runUntil(now)
+	e.lock()
+	defer e.unlock()
+	if err := e.checkOrder(order); err != nil {
+		return err
+	}
+	dispatchedOrder := getDispatchedOrder(e, e.clock, order)
+	e.locateCustomer(dispatchedOrder)
+	e.addPending(dispatchedOrder)
+	e.logEvent(EventOrderDispatched, dispatchedOrder, nil, now)
+	dispatchedCourier := getDispatchedCourier(e, e.clock, e.newCourier(order))
+	dispatchedOrder.courier, dispatchedCourier.order = dispatchedCourier, dispatchedOrder
+	if e.startCooking(dispatchedOrder, now) { // otherwise the order waits for a cook station
+		e.scheduleOrderReady(dispatchedOrder)
+	}
+	// otherwise the order waits for a free courier, or to be ready
+	if !e.dispatchesOnReady() && e.dispatchCourier(dispatchedCourier, now) {
+		e.scheduleTrip(dispatchedCourier)
+	}
+	if e.startBatches() {
+		e.schedule(now.Add(e.strategy.(BatchStrategy).GetWindow()), batchEvent, nil, nil)
+	}
+	return nil
+}
+
+// CancelOrder cancels an order that has not been picked up yet, as of now;
+// events up until now are processed first
+func (e *eventOrderManager) CancelOrder(orderID string) error {
+	now := e.clock.Now()
+	e.runUntil(now)
+	e.lock()
+	defer e.unlock()
+	nextOrder, nextCourier, err := e.cancelOrder(orderID, now)
+	if nextOrder != nil {
+		e.scheduleOrderReady(nextOrder)
+	}
+	if nextCourier != nil {
+		e.scheduleTrip(nextCourier)
+	}
+	return err
+}
+
+// runUntil <private> processes all the events happening until the given time
+func (e *eventOrderManager) runUntil(until time.Time) {
+	for e.events.Len() > 0 && !(*e.events)[0].at.After(until) {
+		e.process(heap.Pop(e.events).(*simulationEvent))
+	}
+}
+
+// Wait runs the simulation until there are no more events, or until the
+// context is done. Orders still pending once the events have run out can never
+// be delivered, which is reported as an error
+func (e *eventOrderManager) Wait(ctx context.Context) error {
+	for e.events.Len() > 0 {
+		if err := ctx.Err(); err != nil {
+			e.stop()
+			return err
+		}
+		e.process(heap.Pop(e.events).(*simulationEvent))
+	}
+	if undelivered := len(e.GetUndeliveredOrders()); undelivered > 0 {
+		e.stop()
+		return fmt.Errorf("%d order(s) can never be delivered", undelivered)
+	}
+	e.flushEvents(ctx)
+	return nil
+}
+
+// process <private> processes an event
+func (e *eventOrderManager) process(event *simulationEvent) {
+	e.lock() // so that the events are delivered as in the concurrent engine
+	defer e.unlock()
+	switch event.kind {
+	case orderReadyEvent:
+		event.order.FinishTime = event.at
+		e.finishOrder(event.order)
+	case courierArrivedEvent:
+		event.courier.ArrivedTime = event.at
+		e.finishPickUp(event.courier)
+	case courierReturnedEvent:
+		event.courier.ReturnedTime = event.at
+		e.finishDelivery(event.courier)
+	case courierTimeoutEvent:
+		replacement, next := e.replaceCourier(event.courier, event.at)
+		if replacement != nil {
+			e.scheduleTrip(replacement)
+		}
+		if next != nil {
+			e.scheduleTrip(next)
+		}
+	case batchEvent:
+		orders, couriers := e.assignBatch(event.at)
+		for i := range orders {
+			e.deliver(orders[i], couriers[i], event.at)
+		}
+		// no more batches once nothing is pending, or once nothing can change
+		if e.pending.Len() > 0 && (e.events.Len() > 0 || len(orders) > 0) {
+			e.schedule(event.at.Add(e.strategy.(BatchStrategy).GetWindow()), batchEvent, nil, nil)
+		} else {
+			e.batching = false
+		}
+	case stackTimeoutEvent:
+		if e.stopHolding(event.courier) { // not full yet
+			e.depart(event.courier, event.at)
+		}
+	}
+}
+
+// deliver <private> picks up the order (and the other waiting orders, if the
+// couriers stack them), and sends the courier off unless it holds on to its
+// orders for more
+func (e *eventOrderManager) deliver(order *DispatchedOrder, courier *DispatchedCourier, at time.Time) {
+	holding := courier.holding != nil
+	if next := e.pickUp(order, courier, at); next != nil {
+		e.scheduleTrip(next)
+	}
+	leave, recalled := e.stackOrders(courier, at)
+	for _, trip := range recalled {
+		e.scheduleTrip(trip)
+	}
+	if leave {
+		e.depart(courier, at)
+	} else if !holding { // leaves once the wait is over, unless it is full by then
+		e.schedule(at.Add(e.getStackWait()), stackTimeoutEvent, nil, courier)
+	}
+}
+
+// depart <private> sends the courier off with its orders; the courier of a
+// limited fleet delivers them and comes back
+func (e *eventOrderManager) depart(courier *DispatchedCourier, at time.Time) {
+	e.departCourier(courier, at)
+	if !e.fleet.unlimited {
+		trip := courier.Courier.DeliveryTime + courier.Courier.ReturnTime
+		e.schedule(at.Add(time.Duration(trip)*time.Second), courierReturnedEvent, nil, courier)
+	}
+}
+
+// finishOrder <private> finish order (food)
+func (e *eventOrderManager) finishOrder(order *DispatchedOrder) error {
+	if order.cancelled { // the station has been freed up already
+		return nil
+	}
+	if next := e.finishCooking(order, order.FinishTime); next != nil {
+		e.scheduleOrderReady(next)
+	}
+	courier, trip := e.matchOrder(order, order.FinishTime)
+	if courier != nil {
+		e.deliver(order, courier, order.FinishTime) // as the concurrent engine does
+	}
+	if trip != nil {
+		e.scheduleTrip(trip)
+	}
+	return nil
+}
+
+// finishPickUp <private> finish pick-up (courier)
+func (e *eventOrderManager) finishPickUp(courier *DispatchedCourier) error {
+	order, wait := e.matchCourier(courier, courier.ArrivedTime)
+	if order != nil {
+		e.deliver(order, courier, courier.ArrivedTime)
+	} else if !wait { // nothing to pick up; the courier is free right away
+		e.releaseAt(courier, courier.ArrivedTime)
+	}
+	return nil
+}
+
+// finishDelivery <private> finish delivery (courier back from the customer)
+func (e *eventOrderManager) finishDelivery(courier *DispatchedCourier) error {
+	e.logEvent(EventCourierReturned, nil, courier, courier.ReturnedTime)
+	e.releaseAt(courier, courier.ReturnedTime)
+	return nil
+}
+
+// releaseAt <private> frees up the courier at the given time, and sends it on
+// the next trip in line
+func (e *eventOrderManager) releaseAt(courier *DispatchedCourier, at time.Time) {
+	if next := e.releaseCourier(courier, at); next != nil {
+		e.scheduleTrip(next)
+	}
+}
+
+// NewEventOrderManager constructs a discrete-event order manager that matches
+// the orders and couriers with the given strategy (e.g. one from GetStrategy)
+func NewEventOrderManager(random *rand.Rand, clk clock.Clock, config Config, strategy Strategy) OrderManager {
+	return &eventOrderManager{
+		orderManagerBase: getOrderManagerBaseClass(random, clk, config, strategy),
+		events:           &eventQueue{},
+	}
+}
+
+// NewMatchedEventOrderManager constructs a discrete-event order manager that
+// uses assigned order strategy
+func NewMatchedEventOrderManager(random *rand.Rand, clk clock.Clock, config Config) OrderManager {
+	return NewEventOrderManager(random, clk, config, getMatchedStrategy())
+}
+
+// NewFIFOEventOrderManager constructs a discrete-event order manager that uses
+// FIFO order strategy
+func NewFIFOEventOrderManager(random *rand.Rand, clk clock.Clock, config Config) OrderManager {
+	return NewEventOrderManager(random, clk, config, getFIFOStrategy())
+}
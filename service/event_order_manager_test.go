@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/suite"
+	"wonsoh.private/cloudkitchens/clock"
+	"wonsoh.private/cloudkitchens/resource"
+)
+
+type EventOrderManagerTestSuite struct {
+	suite.Suite
+	ctrl *gomock.Controller
+}
+
+func (e *EventOrderManagerTestSuite) SetupTest() {
+	e.ctrl = gomock.NewController(e.T())
+}
+
+func (e *EventOrderManagerTestSuite) TestMatchedEventOrderManager() {
+	// same scenario as TestMatchedOrderManager
+	manager := NewMatchedEventOrderManager(getMockRand(e.ctrl), clock.GetSimulatedClock(time.Now()), Config{})
+	for _, order := range testOrders {
+		e.NoError(manager.DispatchOrder(context.Background(), order))
+	}
+	e.NoError(manager.Wait(context.Background()))
+	stats := manager.GetStatistics()
+	e.EqualValues(4, stats.TotalOrderCount)
+	e.EqualValues(4000, stats.TotalFoodWaitTime)
+	e.EqualValues(6000, stats.TotalCourierWaitTime)
+
+	manager.Init(getMockRand(e.ctrl))
+	e.NotPanics(func() {
+		e.NoError(manager.Wait(context.Background()))
+		manager.ReportStatistics()
+	})
+	e.EqualValues(0, manager.GetStatistics().TotalOrderCount)
+}
+
+func (e *EventOrderManagerTestSuite) TestFIFOEventOrderManager() {
+	// same scenario as TestFIFOOrderManager
+	manager := NewFIFOEventOrderManager(getMockRand(e.ctrl), clock.GetSimulatedClock(time.Now()), Config{})
+	for _, order := range testOrders {
+		e.NoError(manager.DispatchOrder(context.Background(), order))
+	}
+	e.NoError(manager.Wait(context.Background()))
+	stats := manager.GetStatistics()
+	e.EqualValues(4, stats.TotalOrderCount)
+	e.EqualValues(1000, stats.TotalFoodWaitTime)
+	e.EqualValues(3000, stats.TotalCourierWaitTime)
+}
+
+func (e *EventOrderManagerTestSuite) TestWaitCancelled() {
+	manager := NewFIFOEventOrderManager(getMockRand(e.ctrl), clock.GetSimulatedClock(time.Now()), Config{})
+	for _, order := range testOrders {
+		e.NoError(manager.DispatchOrder(context.Background(), order))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	e.Equal(context.Canceled, manager.DispatchOrder(ctx, testOrders[0]))
+	e.Equal(context.Canceled, manager.Wait(ctx))
+	e.Equal(testOrders, manager.GetUndeliveredOrders())
+	stats := manager.GetStatistics()
+	e.EqualValues(0, stats.TotalOrderCount)
+	e.EqualValues(len(testOrders), stats.UndeliveredOrderCount)
+}
+
+func (e *EventOrderManagerTestSuite) TestComparableWithConcurrentOrderManager() {
+	orders := make([]*resource.Order, 100)
+	prepTimes := rand.New(rand.NewSource(7))
+	for i := range orders {
+		orders[i] = &resource.Order{
+			ID:       fmt.Sprintf("%d", i),
+			Name:     fmt.Sprintf("Food %d", i),
+			PrepTime: prepTimes.Intn(15) + 1,
+		}
+	}
+	for _, tc := range []struct {
+		name     string
+		strategy string
+		config   Config
+	}{
+		{name: "matched", strategy: MatchedStrategyName},
+		{name: "FIFO", strategy: FIFOStrategyName},
+		{
+			name:     "stacking",
+			strategy: FIFOStrategyName,
+			config:   Config{Fleet: FleetConfig{StackSize: 3}},
+		},
+		{
+			name:     "stacking with a fleet",
+			strategy: FIFOStrategyName,
+			config:   Config{Fleet: FleetConfig{Size: 10, StackSize: 3, StackWait: 5}},
+		},
+	} {
+		start := time.Now()
+		tc.config.Logger = &recordingEventLogger{}
+		var managers []OrderManager
+		for _, getManager := range []func(random *rand.Rand, clk clock.Clock, config Config, strategy Strategy) OrderManager{
+			NewOrderManager,
+			NewEventOrderManager,
+		} {
+			strategy, err := GetStrategy(tc.strategy)
+			e.Require().NoError(err)
+			manager := getManager(resource.GetFixedSeedRandomNumberGenerator(), clock.GetSimulatedClock(start), tc.config, strategy)
+			for _, order := range orders {
+				e.NoError(manager.DispatchOrder(context.Background(), order))
+			}
+			e.NoError(manager.Wait(context.Background()))
+			managers = append(managers, manager)
+		}
+		expected, actual := managers[0].GetStatistics(), managers[1].GetStatistics()
+		e.EqualValues(len(orders), actual.TotalOrderCount, tc.name)
+		e.EqualValues(expected.TotalFoodWaitTime, actual.TotalFoodWaitTime, tc.name)
+		e.EqualValues(expected.TotalCourierWaitTime, actual.TotalCourierWaitTime, tc.name)
+		e.EqualValues(expected.TripCount, actual.TripCount, tc.name)
+		e.EqualValues(expected.TotalHeldTime, actual.TotalHeldTime, tc.name)
+		if tc.config.Fleet.StackSize > 1 {
+			e.Less(actual.TripCount, len(orders), tc.name) // the couriers do stack orders
+		}
+	}
+}
+
+func TestEventOrderManagerTestSuite(t *testing.T) {
+	suite.Run(t, new(EventOrderManagerTestSuite))
+}
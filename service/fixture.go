@@ -1,46 +1,69 @@
 package service
 
 import (
+	"container/list"
+	"context"
 	"log"
 	"time"
 
+	"wonsoh.private/cloudkitchens/clock"
 	"wonsoh.private/cloudkitchens/resource"
 )
 
-// dispatchedOrder represents an event with a dispatched order
-type dispatchedOrder struct {
-	manager      OrderManager
-	Order        *resource.Order
-	StartTime    time.Time
-	FinishTime   time.Time
-	PickedUpTime time.Time
-	notification chan *dispatchedCourier
+// DispatchedOrder represents an event with a dispatched order
+type DispatchedOrder struct {
+	manager   simulation
+	clock     clock.Clock
+	Order     *resource.Order
+	StartTime time.Time
+	// CookStartTime is when a cook station started cooking the order
+	CookStartTime time.Time
+	FinishTime    time.Time
+	PickedUpTime  time.Time
+	// Shelf is the name of the shelf the order waits on for its courier
+	Shelf string
+	// Value is the freshness of the order when it was picked up, between 0 and
+	// 1; or when its courier left the kitchen, if the courier held on to it
+	// waiting to stack more orders
+	Value float64
+	// Reassignments is the number of couriers replaced on the way for the order
+	Reassignments int
+	// Customer is where the order is delivered to (with a geographic model only)
+	Customer *resource.Location
+
+	station   *cookStation       // the station cooking the order
+	decay     float64            // accumulated decay in seconds
+	decayTime time.Time          // when decay was last accumulated
+	courier   *DispatchedCourier // the courier on the way for the order
+	cancelled bool
 }
 
-// dispatchedCourier represents an event with a dispatched courier
-type dispatchedCourier struct {
-	manager        OrderManager
-	Courier        *resource.Courier
+// DispatchedCourier represents an event with a dispatched courier
+type DispatchedCourier struct {
+	manager simulation
+	clock   clock.Clock
+	Courier *resource.Courier
+	// RequestedTime is when the order asked for a courier; the courier is
+	// dispatched later if the whole fleet is busy
+	RequestedTime  time.Time
 	DispatchedTime time.Time
 	ArrivedTime    time.Time
 	PickedUpTime   time.Time
-	notification   chan *dispatchedOrder
+	// ReturnedTime is when the courier of a limited fleet came back after
+	// delivering the order
+	ReturnedTime time.Time
+
+	order    *DispatchedOrder   // the order the courier is on the way for
+	recalled bool               // whether the order has been cancelled on the way
+	carried  []*DispatchedOrder // the orders picked up, in the order they were
+	holding  *list.Element      // while holding on to them at the kitchen for more
 }
 
-func (d *dispatchedOrder) processOrder() {
-	log.Printf(
-		"[ORDER RECEIVED] ID: %s	Name: %s	Prep time: %d second(s)",
-		d.Order.ID,
-		d.Order.Name,
-		d.Order.PrepTime,
-	)
-	time.Sleep(time.Duration(d.Order.PrepTime) * time.Second)
-	d.FinishTime = time.Now()
-	log.Printf(
-		"[ORDER PREPARED] ID: %s	Name: %s",
-		d.Order.ID,
-		d.Order.Name,
-	)
+func (d *DispatchedOrder) processOrder(ctx context.Context) {
+	if d.clock.SleepContext(ctx, time.Duration(d.Order.PrepTime)*time.Second) != nil {
+		return // the simulation has been stopped
+	}
+	d.FinishTime = d.clock.Now()
 	if e := d.manager.finishOrder(d); e != nil {
 		log.Printf(
 			"[ERROR] Error happenned while finishing order for order ID %s (msg: %v)",
@@ -50,22 +73,42 @@ func (d *dispatchedOrder) processOrder() {
 	}
 }
 
-func (d *dispatchedOrder) getWaitTimeInMs() int {
+func (d *DispatchedOrder) getPrepQueueTimeInMs() int {
+	return int(d.CookStartTime.Sub(d.StartTime).Milliseconds())
+}
+
+func (d *DispatchedOrder) getWaitTimeInMs() int {
 	return int(d.PickedUpTime.Sub(d.FinishTime).Milliseconds())
 }
 
-func (d *dispatchedCourier) pickUpOrder() {
-	log.Printf(
-		"[COURIER DISPATCHED] ID: %s	Travel time: %d second(s)",
-		d.Courier.ID,
-		d.Courier.TravelTime,
-	)
-	time.Sleep(time.Duration(d.Courier.TravelTime) * time.Second)
-	d.ArrivedTime = time.Now()
-	log.Printf(
-		"[COURIER ARRIVED] ID: %s",
-		d.Courier.ID,
-	)
+// decayUntil <private> accumulates the decay of the order until the given
+// time, while on a shelf with the given decay modifier
+func (d *DispatchedOrder) decayUntil(now time.Time, modifier float64) {
+	if !d.decayTime.IsZero() {
+		age := now.Sub(d.decayTime).Seconds()
+		d.decay += age * (1 + d.Order.DecayRate*modifier)
+	}
+	d.decayTime = now
+}
+
+// getValue <private> gets the freshness of the order as of the last time the
+// decay was accumulated; food without a shelf life never goes stale
+func (d *DispatchedOrder) getValue() float64 {
+	if d.Order.ShelfLife <= 0 {
+		return 1
+	}
+	value := 1 - d.decay/float64(d.Order.ShelfLife)
+	if value < 0 {
+		return 0
+	}
+	return value
+}
+
+func (d *DispatchedCourier) pickUpOrder(ctx context.Context) {
+	if d.clock.SleepContext(ctx, time.Duration(d.Courier.TravelTime)*time.Second) != nil {
+		return // the simulation has been stopped
+	}
+	d.ArrivedTime = d.clock.Now()
 	if e := d.manager.finishPickUp(d); e != nil {
 		log.Printf(
 			"[ERROR] Error happenned while picking up order for courier ID %s (msg: %v)",
@@ -75,71 +118,72 @@ func (d *dispatchedCourier) pickUpOrder() {
 	}
 }
 
-func (d *dispatchedCourier) getWaitTimeInMs() int {
+// deliverOrder delivers the picked up order to the customer and comes back,
+// after which the courier is free for another trip
+func (d *DispatchedCourier) deliverOrder(ctx context.Context) {
+	trip := time.Duration(d.Courier.DeliveryTime+d.Courier.ReturnTime) * time.Second
+	if d.clock.SleepContext(ctx, trip) != nil {
+		return // the simulation has been stopped
+	}
+	d.ReturnedTime = d.clock.Now()
+	if e := d.manager.finishDelivery(d); e != nil {
+		log.Printf(
+			"[ERROR] Error happenned while finishing delivery for courier ID %s (msg: %v)",
+			d.Courier.ID,
+			e,
+		)
+	}
+}
+
+func (d *DispatchedCourier) getQueueTimeInMs() int {
+	return int(d.DispatchedTime.Sub(d.RequestedTime).Milliseconds())
+}
+
+func (d *DispatchedCourier) getWaitTimeInMs() int {
 	return int(d.PickedUpTime.Sub(d.ArrivedTime).Milliseconds())
 }
 
-func logPickUpEvent(
-	order *dispatchedOrder,
-	courier *dispatchedCourier,
-) {
-	orderPickUpTime := order.PickedUpTime
-	courierPickUpTime := courier.PickedUpTime
-	actualPickUpTime := orderPickUpTime // choose the maximum between the two
-	if courierPickUpTime.After(orderPickUpTime) {
-		actualPickUpTime = courierPickUpTime
+// getOrderRecord <private> gets the record of a picked up order
+func getOrderRecord(order *DispatchedOrder, courier *DispatchedCourier) resource.OrderRecord {
+	return resource.OrderRecord{
+		OrderID:         order.Order.ID,
+		Name:            order.Order.Name,
+		PrepTime:        order.Order.PrepTime,
+		CourierID:       courier.Courier.ID,
+		TravelTime:      courier.Courier.TravelTime,
+		DispatchedAt:    order.StartTime,
+		ReadyAt:         order.FinishTime,
+		ArrivedAt:       courier.ArrivedTime,
+		PickedUpAt:      order.PickedUpTime,
+		FoodWaitTime:    order.getWaitTimeInMs(),
+		CourierWaitTime: courier.getWaitTimeInMs(),
 	}
-
-	log.Printf(
-		`
-		===============================================================
-		[COURIER PICKED UP FOOD]
-		---------------------------------------------------------------
-		Courier ID:	%s
-		Courier Dispatched: %s	Arrived: %s	Picked Up At:	%s
-		---------------------------------------------------------------
-		Order Name:	%s
-		Order ID:	%s
-		Food Cooking Started: %s	Finished: %s	Picked Up At: %s
-		---------------------------------------------------------------
-		Courier has been waiting for:	%d ms
-		Food has been waiting for: %d ms
-		===============================================================
-		`,
-		courier.Courier.ID,
-		courier.DispatchedTime.Format(time.StampMilli),
-		courier.ArrivedTime.Format(time.StampMilli),
-		courier.PickedUpTime.Format(time.StampMilli),
-		order.Order.Name,
-		order.Order.ID,
-		order.StartTime.Format(time.StampMilli),
-		order.FinishTime.Format(time.StampMilli),
-		order.StartTime.Format(time.StampMilli),
-		actualPickUpTime.Sub(courier.ArrivedTime).Milliseconds(),
-		actualPickUpTime.Sub(order.FinishTime).Milliseconds(),
-	)
 }
 
 func getDispatchedOrder(
-	m OrderManager,
+	m simulation,
+	clk clock.Clock,
 	order *resource.Order,
-) *dispatchedOrder {
-	return &dispatchedOrder{
-		manager:      m,
-		Order:        order,
-		StartTime:    time.Now(),
-		notification: make(chan *dispatchedCourier),
+) *DispatchedOrder {
+	return &DispatchedOrder{
+		manager:   m,
+		clock:     clk,
+		Order:     order,
+		StartTime: clk.Now(),
 	}
 }
 
 func getDispatchedCourier(
-	m OrderManager,
+	m simulation,
+	clk clock.Clock,
 	courier *resource.Courier,
-) *dispatchedCourier {
-	return &dispatchedCourier{
+) *DispatchedCourier {
+	now := clk.Now()
+	return &DispatchedCourier{
 		manager:        m,
+		clock:          clk,
 		Courier:        courier,
-		DispatchedTime: time.Now(),
-		notification:   make(chan *dispatchedOrder),
+		RequestedTime:  now,
+		DispatchedTime: now,
 	}
 }
@@ -1,12 +1,14 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"math/rand"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/suite"
+	"wonsoh.private/cloudkitchens/clock"
 	"wonsoh.private/cloudkitchens/resource"
 )
 
@@ -17,40 +19,65 @@ type FixtureTestSuite struct {
 }
 
 type mockOrderManager struct {
-	dispatchOrderError bool
-	finishOrderError   bool
-	finishPickUpError  bool
+	dispatchOrderError  bool
+	finishOrderError    bool
+	finishPickUpError   bool
+	finishDeliveryError bool
 }
 
 func (m *mockOrderManager) reset() {
 	m.dispatchOrderError = false
 	m.finishOrderError = false
 	m.finishPickUpError = false
+	m.finishDeliveryError = false
 }
 func (m *mockOrderManager) Init(random *rand.Rand) {}
-func (m *mockOrderManager) DispatchOrder(order *resource.Order) error {
+func (m *mockOrderManager) DispatchOrder(ctx context.Context, order *resource.Order) error {
 	if m.dispatchOrderError {
 		return errors.New("DispatchOrder errror")
 	}
 	return nil
 }
-func (m *mockOrderManager) Wait()             {}
+func (m *mockOrderManager) Wait(ctx context.Context) error {
+	return nil
+}
+func (m *mockOrderManager) CancelOrder(orderID string) error {
+	return nil
+}
 func (m *mockOrderManager) ReportStatistics() {}
 func (m *mockOrderManager) GetStatistics() *OrderManagerStatistics {
 	return nil
 }
-func (m *mockOrderManager) finishOrder(d *dispatchedOrder) error {
+func (m *mockOrderManager) GetUndeliveredOrders() []*resource.Order {
+	return nil
+}
+func (m *mockOrderManager) GetRecords() []resource.OrderRecord {
+	return nil
+}
+func (m *mockOrderManager) Subscribe(listener Listener) func() {
+	return func() {}
+}
+func (m *mockOrderManager) SubscribeChannel(size int) *ChannelSubscription {
+	return nil
+}
+func (m *mockOrderManager) finishOrder(d *DispatchedOrder) error {
 	if m.finishOrderError {
 		return errors.New("finishOrder error")
 	}
 	return nil
 }
-func (m *mockOrderManager) finishPickUp(d *dispatchedCourier) error {
+func (m *mockOrderManager) finishPickUp(d *DispatchedCourier) error {
 	if m.finishPickUpError {
 		return errors.New("finishPickUp error")
 	}
 	return nil
 }
+func (m *mockOrderManager) finishDelivery(d *DispatchedCourier) error {
+	if m.finishDeliveryError {
+		return errors.New("finishDelivery error")
+	}
+	return nil
+}
 
 func (f *FixtureTestSuite) SetupTest() {
 	f.mockOrderManager = &mockOrderManager{}
@@ -61,37 +88,56 @@ func (f *FixtureTestSuite) BeforeTest() {
 }
 
 func (f *FixtureTestSuite) TestDispatchedOrder() {
-	order := getDispatchedOrder(f.mockOrderManager, &resource.Order{
+	order := getDispatchedOrder(f.mockOrderManager, clock.GetRealClock(), &resource.Order{
 		ID:       "1",
 		Name:     "Test Food",
 		PrepTime: 1,
 	})
 
 	start := time.Now()
-	order.processOrder()
+	order.processOrder(context.Background())
 	f.GreaterOrEqual(time.Now().Sub(start).Seconds(), float64(1))
 	f.mockOrderManager.finishOrderError = true
 	f.NotPanics(func() {
-		order.processOrder()
+		order.processOrder(context.Background())
 	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	order.FinishTime = time.Time{}
+	order.processOrder(ctx) // the simulation has been stopped
+	f.True(order.FinishTime.IsZero())
 	order.PickedUpTime = order.FinishTime.Add(time.Hour)
 	f.EqualValues(time.Hour.Milliseconds(), order.getWaitTimeInMs())
 
 }
 
 func (f *FixtureTestSuite) TestDispatchedCourier() {
-	courier := getDispatchedCourier(f.mockOrderManager, resource.NewCourier("1", 1))
+	courier := getDispatchedCourier(f.mockOrderManager, clock.GetRealClock(), resource.NewCourier("1", 1))
 	start := time.Now()
-	courier.pickUpOrder()
+	courier.pickUpOrder(context.Background())
 	f.GreaterOrEqual(time.Now().Sub(start).Seconds(), float64(1))
 	f.mockOrderManager.finishPickUpError = true
 	f.NotPanics(func() {
-		courier.pickUpOrder()
+		courier.pickUpOrder(context.Background())
 	})
 	courier.PickedUpTime = courier.ArrivedTime.Add(time.Minute)
 	f.EqualValues(time.Minute.Milliseconds(), courier.getWaitTimeInMs())
 }
 
+func (f *FixtureTestSuite) TestCourierDelivery() {
+	clk := clock.GetSimulatedClock(time.Unix(0, 0))
+	courier := getDispatchedCourier(f.mockOrderManager, clk, resource.NewCourier("1", 1))
+	courier.Courier.DeliveryTime, courier.Courier.ReturnTime = 5, 5
+	courier.deliverOrder(context.Background())
+	f.Equal(time.Unix(10, 0), courier.ReturnedTime) // there and back again
+	f.mockOrderManager.finishDeliveryError = true
+	f.NotPanics(func() {
+		courier.deliverOrder(context.Background())
+	})
+	courier.DispatchedTime = courier.RequestedTime.Add(time.Second)
+	f.EqualValues(time.Second.Milliseconds(), courier.getQueueTimeInMs())
+}
+
 func TestFixtureTestSuite(t *testing.T) {
 	suite.Run(t, new(FixtureTestSuite))
 }
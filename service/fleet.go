@@ -0,0 +1,153 @@
+package service
+
+import (
+	"container/list"
+	"time"
+
+	"github.com/google/uuid"
+	"wonsoh.private/cloudkitchens/resource"
+)
+
+// FleetConfig configures the couriers that pick up the orders
+type FleetConfig struct {
+	// Size is the number of couriers; zero (or less) means that a new courier is
+	// dispatched for every order, and never comes back
+	Size int
+	// NoShowProbability is the probability that a dispatched courier never
+	// shows up at the kitchen (at least 0, and less than 1)
+	NoShowProbability float64
+	// NoShowTimeout is the time in seconds after being dispatched that a
+	// courier who has not arrived yet is replaced by a new one; zero (or less)
+	// means DefaultNoShowTimeout (on top of the longest travel time with a
+	// geographic model)
+	NoShowTimeout int
+	// StackSize is the most orders a courier picks up in a single visit to the
+	// kitchen, when several prepared orders are waiting (with a strategy that
+	// stacks orders, such as FIFO); one (or less) means a single order
+	StackSize int
+	// StackWait is the longest time in seconds that a courier holding fewer
+	// than StackSize orders waits at the kitchen for more to be prepared; zero
+	// (or less) means it leaves with the orders that are ready
+	StackWait int
+}
+
+// DefaultNoShowTimeout is the default time in seconds that a courier has to
+// arrive at the kitchen before being replaced; it is longer than any travel
+// time, so only the couriers who never show up are replaced
+const DefaultNoShowTimeout = 30
+
+// fleet hands out the free couriers of a limited fleet to the dispatched
+// trips, and keeps the trips waiting for a free courier in the order they
+// were dispatched. A courier is free again once it has delivered the order it
+// picked up and come back (or, with a geographic model, right where it
+// delivered the order).
+// Not thread-safe; the order managers call it while holding their lock
+type fleet struct {
+	unlimited bool       // whether there are as many couriers as needed
+	geography *geography // where the free couriers set off from
+	chooser   CourierChooser
+	idle      *list.List // the free couriers, the longest free first (*idleCourier)
+	queue     *list.List // trips waiting for a free courier (*DispatchedCourier)
+}
+
+// idleCourier is a free courier of the fleet
+type idleCourier struct {
+	id       string
+	location *resource.Location // where the courier is (with a geographic model only)
+}
+
+func (f *fleet) assign(idle *idleCourier, courier *DispatchedCourier, now time.Time) {
+	courier.Courier.ID = idle.id
+	courier.DispatchedTime = now
+	if f.geography.enabled() {
+		f.geography.setOff(courier.Courier, idle.location)
+	}
+}
+
+// dispatch assigns a free courier to the trip, or queues it up if there is
+// none; returns whether the courier has been dispatched
+func (f *fleet) dispatch(courier *DispatchedCourier, now time.Time) bool {
+	if f.unlimited {
+		courier.DispatchedTime = now
+		return true
+	}
+	if f.idle.Len() == 0 {
+		f.queue.PushBack(courier)
+		return false
+	}
+	f.assign(f.idle.Remove(f.choose()).(*idleCourier), courier, now)
+	return true
+}
+
+// choose gets the free courier to dispatch; the one that has been free the
+// longest unless the fleet has a chooser
+func (f *fleet) choose() *list.Element {
+	if f.chooser == nil {
+		return f.idle.Front()
+	}
+	elements := make([]*list.Element, 0, f.idle.Len())
+	free := make([]*resource.Courier, 0, f.idle.Len())
+	for element := f.idle.Front(); element != nil; element = element.Next() {
+		idle := element.Value.(*idleCourier)
+		elements = append(elements, element)
+		free = append(free, &resource.Courier{ID: idle.id, Location: idle.location})
+	}
+	return elements[f.chooser.ChooseCourier(f.geography.config.Kitchen, free)]
+}
+
+// release frees up the courier of a finished trip at the given location (nil
+// without a geographic model), and returns the earliest queued trip that the
+// courier is dispatched on (or nil if none)
+func (f *fleet) release(
+	courier *DispatchedCourier,
+	location *resource.Location,
+	now time.Time,
+) *DispatchedCourier {
+	if f.unlimited {
+		return nil
+	}
+	idle := &idleCourier{
+		id:       courier.Courier.ID,
+		location: location,
+	}
+	if f.queue.Len() == 0 {
+		f.idle.PushBack(idle)
+		return nil
+	}
+	next := f.queue.Remove(f.queue.Front()).(*DispatchedCourier)
+	f.assign(idle, next, now)
+	return next
+}
+
+// cancel takes a trip out of the queue; returns whether it was waiting for a
+// free courier
+func (f *fleet) cancel(courier *DispatchedCourier) bool {
+	for element := f.queue.Front(); element != nil; element = element.Next() {
+		if element.Value.(*DispatchedCourier) == courier {
+			f.queue.Remove(element)
+			return true
+		}
+	}
+	return false
+}
+
+// getFleet gets a fleet of couriers, whose free couriers are dispatched by the
+// chooser if any; with a geographic model, the couriers start off at random
+// locations
+func getFleet(config FleetConfig, geography *geography, chooser CourierChooser) *fleet {
+	f := &fleet{
+		unlimited: config.Size <= 0,
+		geography: geography,
+		chooser:   chooser,
+		idle:      list.New(),
+		queue:     list.New(),
+	}
+	for i := 0; i < config.Size; i++ {
+		idle := &idleCourier{id: uuid.NewString()}
+		if geography.enabled() {
+			idle.location = geography.randomLocation()
+		}
+		f.idle.PushBack(idle)
+	}
+	return f
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"wonsoh.private/cloudkitchens/clock"
+	"wonsoh.private/cloudkitchens/resource"
+)
+
+type FleetTestSuite struct {
+	suite.Suite
+	now time.Time
+}
+
+func (f *FleetTestSuite) SetupTest() {
+	f.now = time.Now()
+}
+
+func (f *FleetTestSuite) getCourier(orderID string) *DispatchedCourier {
+	return getDispatchedCourier(nil, clock.GetSimulatedClock(f.now), resource.NewCourier(orderID, 3))
+}
+
+func (f *FleetTestSuite) TestUnlimited() {
+	fleet := getFleet(FleetConfig{}, getGeography(GeoConfig{}, nil), nil)
+	for i := 0; i < 100; i++ {
+		courier := f.getCourier("1")
+		id := courier.Courier.ID
+		f.True(fleet.dispatch(courier, f.now))
+		f.Equal(id, courier.Courier.ID) // a new courier for every order
+		f.Nil(fleet.release(courier, nil, f.now))
+	}
+}
+
+func (f *FleetTestSuite) TestLimited() {
+	fleet := getFleet(FleetConfig{Size: 2}, getGeography(GeoConfig{}, nil), nil)
+	first, second, third := f.getCourier("1"), f.getCourier("2"), f.getCourier("3")
+	f.True(fleet.dispatch(first, f.now))
+	f.True(fleet.dispatch(second, f.now))
+	f.NotEqual(first.Courier.ID, second.Courier.ID)
+	f.False(fleet.dispatch(third, f.now))
+
+	later := f.now.Add(10 * time.Second)
+	f.Equal(third, fleet.release(second, nil, later)) // reused by the trip waiting in line
+	f.Equal(second.Courier.ID, third.Courier.ID)
+	f.Equal(later, third.DispatchedTime)
+	f.EqualValues(10000, third.getQueueTimeInMs())
+
+	f.Nil(fleet.release(first, nil, later))
+	fourth := f.getCourier("4")
+	f.True(fleet.dispatch(fourth, later))
+	f.Equal(first.Courier.ID, fourth.Courier.ID)
+}
+
+func (f *FleetTestSuite) TestCancel() {
+	fleet := getFleet(FleetConfig{Size: 1}, getGeography(GeoConfig{}, nil), nil)
+	first, second, third := f.getCourier("1"), f.getCourier("2"), f.getCourier("3")
+	f.True(fleet.dispatch(first, f.now))
+	f.False(fleet.dispatch(second, f.now))
+	f.False(fleet.dispatch(third, f.now))
+	f.False(fleet.cancel(first)) // on the way already
+	f.True(fleet.cancel(second))
+	f.Equal(third, fleet.release(first, nil, f.now)) // skips the cancelled trip
+	f.False(getFleet(FleetConfig{}, getGeography(GeoConfig{}, nil), nil).cancel(first))
+}
+
+func (f *FleetTestSuite) TestGeography() {
+	geography := getGeography(GeoConfig{Radius: 100, Speed: 10}, resource.GetFixedSeedRandomNumberGenerator())
+	fleet := getFleet(FleetConfig{Size: 1}, geography, nil)
+	first, second := f.getCourier("1"), f.getCourier("2")
+	f.True(fleet.dispatch(first, f.now))
+	f.NotNil(first.Courier.Location) // sets off from where it starts off
+	f.Equal(first.Courier.Location.Distance(resource.Location{}), first.Courier.Distance)
+	f.Equal(geography.getTravelTime(first.Courier.Distance), first.Courier.TravelTime)
+	f.False(fleet.dispatch(second, f.now))
+
+	customer := &resource.Location{X: 30, Y: -40}
+	f.Equal(second, fleet.release(first, customer, f.now)) // sets off from where it is free
+	f.Equal(customer, second.Courier.Location)
+	f.EqualValues(70, second.Courier.Distance)
+	f.EqualValues(7, second.Courier.TravelTime)
+}
+
+func (f *FleetTestSuite) TestChooser() {
+	// the couriers start off at random locations; the closest one is dispatched
+	geography := getGeography(GeoConfig{Radius: 100}, resource.GetFixedSeedRandomNumberGenerator())
+	fleet := getFleet(FleetConfig{Size: 2}, geography, getNearestStrategy())
+	var locations []*resource.Location
+	for element := fleet.idle.Front(); element != nil; element = element.Next() {
+		locations = append(locations, element.Value.(*idleCourier).location)
+	}
+	nearest := locations[0]
+	if locations[1].Distance(resource.Location{}) < nearest.Distance(resource.Location{}) {
+		nearest = locations[1]
+	}
+	first := f.getCourier("1")
+	f.True(fleet.dispatch(first, f.now))
+	f.Equal(nearest, first.Courier.Location)
+}
+
+func TestFleetTestSuite(t *testing.T) {
+	suite.Run(t, new(FleetTestSuite))
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"math"
+	"math/rand"
+
+	"wonsoh.private/cloudkitchens/resource"
+)
+
+// DefaultCourierSpeed is the default speed of the couriers in meters per
+// second (18 km/h)
+const DefaultCourierSpeed = 5.0
+
+// GeoConfig configures where the kitchen, the customers and the couriers are;
+// the zero value disables the geographic model, and every travel time is drawn
+// uniformly between 3 and 15 seconds instead
+type GeoConfig struct {
+	// Kitchen is where the kitchen is
+	Kitchen resource.Location
+	// Radius is how far in meters from the kitchen (along either axis) the
+	// customers and the couriers of the fleet are placed at random; zero (or
+	// less) disables the geographic model
+	Radius float64
+	// Speed is the speed of the couriers in meters per second; zero (or less)
+	// means DefaultCourierSpeed
+	Speed float64
+}
+
+// geography places the customers and the couriers around the kitchen, and
+// gets the travel times from the distances along the streets.
+// Not thread-safe; the order managers call it while holding their lock
+type geography struct {
+	config GeoConfig
+	random *rand.Rand
+}
+
+// enabled returns whether the travel times come from the distances
+func (g *geography) enabled() bool {
+	return g.config.Radius > 0
+}
+
+// randomLocation draws a location within the radius around the kitchen
+func (g *geography) randomLocation() *resource.Location {
+	draw := rand.Float64
+	if g.random != nil {
+		draw = g.random.Float64
+	}
+	return &resource.Location{
+		X: g.config.Kitchen.X + (2*draw()-1)*g.config.Radius,
+		Y: g.config.Kitchen.Y + (2*draw()-1)*g.config.Radius,
+	}
+}
+
+// getTravelTime gets the time in seconds to travel the distance, rounded to
+// the nearest second
+func (g *geography) getTravelTime(distance float64) int {
+	speed := g.config.Speed
+	if speed <= 0 {
+		speed = DefaultCourierSpeed
+	}
+	return int(math.Round(distance / speed))
+}
+
+// getLongestTravelTime gets the time in seconds to travel from the farthest
+// location within the radius to the kitchen (zero without a geographic model)
+func (g *geography) getLongestTravelTime() int {
+	if !g.enabled() {
+		return 0
+	}
+	return g.getTravelTime(2 * g.config.Radius)
+}
+
+// setOff sends the courier to the kitchen from the given location
+func (g *geography) setOff(courier *resource.Courier, from *resource.Location) {
+	courier.Location = from
+	courier.Distance = from.Distance(g.config.Kitchen)
+	courier.TravelTime = g.getTravelTime(courier.Distance)
+}
+
+// deliver sends the courier from the kitchen to the customer of the order
+func (g *geography) deliver(courier *resource.Courier, customer *resource.Location) {
+	g.route(courier, []*resource.Location{customer})
+}
+
+// route sends the courier from the kitchen to each of the customers in turn
+func (g *geography) route(courier *resource.Courier, customers []*resource.Location) {
+	from := g.config.Kitchen
+	courier.DeliveryDistance = 0
+	for _, customer := range customers {
+		courier.DeliveryDistance += from.Distance(*customer)
+		from = *customer
+	}
+	courier.DeliveryTime = g.getTravelTime(courier.DeliveryDistance)
+}
+
+func getGeography(config GeoConfig, random *rand.Rand) *geography {
+	return &geography{
+		config: config,
+		random: random,
+	}
+}
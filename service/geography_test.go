@@ -0,0 +1,72 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"wonsoh.private/cloudkitchens/resource"
+)
+
+type GeographyTestSuite struct {
+	suite.Suite
+}
+
+func (g *GeographyTestSuite) TestEnabled() {
+	g.False(getGeography(GeoConfig{}, nil).enabled())
+	g.True(getGeography(GeoConfig{Radius: 1}, nil).enabled())
+}
+
+func (g *GeographyTestSuite) TestRandomLocation() {
+	kitchen := resource.Location{X: 100, Y: -100}
+	geography := getGeography(GeoConfig{Kitchen: kitchen, Radius: 50}, resource.GetFixedSeedRandomNumberGenerator())
+	for i := 0; i < 100; i++ {
+		location := geography.randomLocation()
+		g.InDelta(kitchen.X, location.X, 50)
+		g.InDelta(kitchen.Y, location.Y, 50)
+	}
+	// the same locations are drawn from the same seed
+	first := getGeography(GeoConfig{Radius: 50}, resource.GetFixedSeedRandomNumberGenerator())
+	second := getGeography(GeoConfig{Radius: 50}, resource.GetFixedSeedRandomNumberGenerator())
+	for i := 0; i < 100; i++ {
+		g.Equal(first.randomLocation(), second.randomLocation())
+	}
+	g.NotNil(getGeography(GeoConfig{Radius: 50}, nil).randomLocation())
+}
+
+func (g *GeographyTestSuite) TestGetTravelTime() {
+	g.EqualValues(20, getGeography(GeoConfig{}, nil).getTravelTime(100)) // at the default speed
+	geography := getGeography(GeoConfig{Speed: 10}, nil)
+	g.EqualValues(0, geography.getTravelTime(0))
+	g.EqualValues(10, geography.getTravelTime(104))
+	g.EqualValues(11, geography.getTravelTime(105))
+}
+
+func (g *GeographyTestSuite) TestGetLongestTravelTime() {
+	g.EqualValues(0, getGeography(GeoConfig{Speed: 10}, nil).getLongestTravelTime())
+	g.EqualValues(20, getGeography(GeoConfig{Radius: 100, Speed: 10}, nil).getLongestTravelTime())
+}
+
+func (g *GeographyTestSuite) TestSetOffAndDeliver() {
+	geography := getGeography(GeoConfig{Kitchen: resource.Location{X: 10, Y: 10}, Radius: 100, Speed: 10}, nil)
+	courier := resource.NewCourier("1", 0)
+	from := &resource.Location{X: -40, Y: 10}
+	geography.setOff(courier, from)
+	g.Equal(from, courier.Location)
+	g.EqualValues(50, courier.Distance)
+	g.EqualValues(5, courier.TravelTime)
+	geography.deliver(courier, &resource.Location{X: 30, Y: 50})
+	g.EqualValues(60, courier.DeliveryDistance)
+	g.EqualValues(6, courier.DeliveryTime)
+}
+
+func (g *GeographyTestSuite) TestRoute() {
+	geography := getGeography(GeoConfig{Radius: 100, Speed: 10}, nil)
+	courier := resource.NewCourier("1", 0)
+	geography.route(courier, []*resource.Location{{X: 30, Y: 40}, {X: -20, Y: 40}, {X: -20, Y: 0}})
+	g.EqualValues(160, courier.DeliveryDistance) // 70 m, then 50 m, then 40 m
+	g.EqualValues(16, courier.DeliveryTime)
+}
+
+func TestGeographyTestSuite(t *testing.T) {
+	suite.Run(t, new(GeographyTestSuite))
+}
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"container/list"
+	"time"
+
+	"wonsoh.private/cloudkitchens/resource"
+)
+
+// KitchenConfig configures the cook stations that prepare the orders
+type KitchenConfig struct {
+	// CookStations is the number of cook stations that cook food of any
+	// temperature; zero (or less) means that there are as many as needed
+	CookStations int
+	// SpecializedCookStations is the number of cook stations dedicated to food
+	// of each temperature; orders go to them before the general ones
+	SpecializedCookStations map[string]int
+}
+
+// cookStation is a station that cooks one order at a time
+type cookStation struct {
+	// temperature is the temperature of the food the station is dedicated to,
+	// or empty for a general station
+	temperature string
+	order       *DispatchedOrder
+}
+
+// kitchen assigns the dispatched orders to free cook stations, and keeps the
+// orders waiting for a station in the order they were dispatched.
+// Not thread-safe; the order managers call it while holding their lock
+type kitchen struct {
+	stations  []*cookStation // specialized stations come first
+	unlimited bool           // whether there are as many general stations as needed
+	queue     *list.List     // orders waiting for a station (*DispatchedOrder)
+
+	busyTime   time.Duration // total time the stations spent cooking
+	firstStart time.Time
+	lastFinish time.Time
+}
+
+func (c *cookStation) canCook(order *DispatchedOrder) bool {
+	return c.temperature == "" || c.temperature == order.Order.Temp
+}
+
+func (k *kitchen) assign(station *cookStation, order *DispatchedOrder, now time.Time) {
+	if k.firstStart.IsZero() {
+		k.firstStart = now
+	}
+	order.station = station
+	order.CookStartTime = now
+	if station != nil {
+		station.order = order
+	}
+}
+
+// startCooking assigns the order to a free cook station, or queues it up if
+// there is none; returns whether the order has started cooking
+func (k *kitchen) startCooking(order *DispatchedOrder, now time.Time) bool {
+	for _, station := range k.stations {
+		if station.order == nil && station.canCook(order) {
+			k.assign(station, order, now)
+			return true
+		}
+	}
+	if k.unlimited {
+		k.assign(nil, order, now)
+		return true
+	}
+	k.queue.PushBack(order)
+	return false
+}
+
+// finishCooking frees up the cook station of the prepared order, and returns
+// the earliest queued order that the station starts cooking (or nil if none)
+func (k *kitchen) finishCooking(order *DispatchedOrder, now time.Time) *DispatchedOrder {
+	station := order.station
+	order.station = nil
+	if station == nil {
+		return nil
+	}
+	station.order = nil
+	k.busyTime += now.Sub(order.CookStartTime)
+	if now.After(k.lastFinish) {
+		k.lastFinish = now
+	}
+	for element := k.queue.Front(); element != nil; element = element.Next() {
+		if next := element.Value.(*DispatchedOrder); station.canCook(next) {
+			k.queue.Remove(element)
+			k.assign(station, next, now)
+			return next
+		}
+	}
+	return nil
+}
+
+// cancel takes a cancelled order out of the queue, or frees up the cook station
+// cooking it; returns the earliest queued order that the station starts
+// cooking (or nil if none)
+func (k *kitchen) cancel(order *DispatchedOrder, now time.Time) *DispatchedOrder {
+	for element := k.queue.Front(); element != nil; element = element.Next() {
+		if element.Value.(*DispatchedOrder) == order {
+			k.queue.Remove(element)
+			return nil
+		}
+	}
+	return k.finishCooking(order, now)
+}
+
+// getUtilization gets the fraction of time the cook stations spent cooking,
+// from the first order started cooking until the last one finished; a kitchen
+// without any limited station always has zero utilization
+func (k *kitchen) getUtilization() float64 {
+	span := k.lastFinish.Sub(k.firstStart)
+	if len(k.stations) == 0 || span <= 0 {
+		return 0
+	}
+	return k.busyTime.Seconds() / (span.Seconds() * float64(len(k.stations)))
+}
+
+func getKitchen(config KitchenConfig) *kitchen {
+	k := &kitchen{
+		unlimited: config.CookStations <= 0,
+		queue:     list.New(),
+	}
+	// in a fixed order of temperatures, so that the assignments are deterministic
+	for _, temperature := range []string{
+		resource.TemperatureHot,
+		resource.TemperatureCold,
+		resource.TemperatureFrozen,
+	} {
+		for i := 0; i < config.SpecializedCookStations[temperature]; i++ {
+			k.stations = append(k.stations, &cookStation{temperature: temperature})
+		}
+	}
+	for i := 0; i < config.CookStations; i++ {
+		k.stations = append(k.stations, &cookStation{})
+	}
+	return k
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"wonsoh.private/cloudkitchens/clock"
+	"wonsoh.private/cloudkitchens/resource"
+)
+
+type KitchenTestSuite struct {
+	suite.Suite
+	now time.Time
+}
+
+func (k *KitchenTestSuite) SetupTest() {
+	k.now = time.Now()
+}
+
+func (k *KitchenTestSuite) getOrder(id string, temp string) *DispatchedOrder {
+	return getDispatchedOrder(nil, clock.GetSimulatedClock(k.now), &resource.Order{
+		ID:   id,
+		Name: "Food " + id,
+		Temp: temp,
+	})
+}
+
+func (k *KitchenTestSuite) TestUnlimited() {
+	kitchen := getKitchen(KitchenConfig{})
+	for i := 0; i < 100; i++ {
+		order := k.getOrder("1", resource.TemperatureHot)
+		k.True(kitchen.startCooking(order, k.now))
+		k.Equal(k.now, order.CookStartTime)
+	}
+	k.Nil(kitchen.finishCooking(k.getOrder("1", resource.TemperatureHot), k.now))
+	k.EqualValues(0, kitchen.getUtilization())
+}
+
+func (k *KitchenTestSuite) TestGeneralStations() {
+	kitchen := getKitchen(KitchenConfig{CookStations: 2})
+	first, second, third := k.getOrder("1", ""), k.getOrder("2", ""), k.getOrder("3", "")
+	k.True(kitchen.startCooking(first, k.now))
+	k.True(kitchen.startCooking(second, k.now))
+	k.False(kitchen.startCooking(third, k.now))
+
+	later := k.now.Add(2 * time.Second)
+	k.Equal(third, kitchen.finishCooking(second, later))
+	k.Equal(later, third.CookStartTime)
+	k.Nil(kitchen.finishCooking(first, k.now.Add(4*time.Second)))
+	k.Nil(kitchen.finishCooking(third, k.now.Add(4*time.Second)))
+	// 2 + 4 + 2 seconds of cooking over 4 seconds on 2 stations
+	k.InDelta(1.0, kitchen.getUtilization(), 1e-9)
+}
+
+func (k *KitchenTestSuite) TestSpecializedStations() {
+	kitchen := getKitchen(KitchenConfig{
+		CookStations:            1,
+		SpecializedCookStations: map[string]int{resource.TemperatureCold: 1},
+	})
+	cold1, cold2 := k.getOrder("1", resource.TemperatureCold), k.getOrder("2", resource.TemperatureCold)
+	hot1, hot2 := k.getOrder("3", resource.TemperatureHot), k.getOrder("4", resource.TemperatureHot)
+	k.True(kitchen.startCooking(cold1, k.now)) // on the cold station
+	k.True(kitchen.startCooking(hot1, k.now))  // on the general station
+	k.False(kitchen.startCooking(hot2, k.now))
+	k.False(kitchen.startCooking(cold2, k.now))
+
+	// the cold station skips the hot order waiting ahead
+	k.Equal(cold2, kitchen.finishCooking(cold1, k.now.Add(time.Second)))
+	k.Equal(hot2, kitchen.finishCooking(hot1, k.now.Add(time.Second)))
+}
+
+func (k *KitchenTestSuite) TestCancel() {
+	kitchen := getKitchen(KitchenConfig{CookStations: 1})
+	first, second, third := k.getOrder("1", ""), k.getOrder("2", ""), k.getOrder("3", "")
+	k.True(kitchen.startCooking(first, k.now))
+	k.False(kitchen.startCooking(second, k.now))
+	k.False(kitchen.startCooking(third, k.now))
+
+	k.Nil(kitchen.cancel(second, k.now)) // taken out of the queue
+	later := k.now.Add(time.Second)
+	k.Equal(third, kitchen.cancel(first, later)) // frees up the station
+	k.Equal(later, third.CookStartTime)
+	k.Nil(kitchen.finishCooking(third, k.now.Add(2*time.Second)))
+}
+
+func TestKitchenTestSuite(t *testing.T) {
+	suite.Run(t, new(KitchenTestSuite))
+}
@@ -2,24 +2,98 @@ package service
 
 import (
 	"container/list"
+	"context"
+	"errors"
+	"fmt"
 	"log"
 	"math/rand"
+	"os"
 	"sync"
 	"time"
 
+	"wonsoh.private/cloudkitchens/clock"
 	"wonsoh.private/cloudkitchens/resource"
 )
 
-var (
-	matchedOrderManagerInstance OrderManager
-	fifoOrderManagerInstance    OrderManager
-)
+// ErrOrderNotFound is returned when cancelling an order that has not been
+// dispatched, or that has already been picked up or thrown away
+var ErrOrderNotFound = errors.New("no undelivered order")
+
+// ErrNilOrder is returned when dispatching a nil order
+var ErrNilOrder = errors.New("nil order")
+
+// ErrDuplicateOrder is returned when dispatching an order with the ID of an
+// undelivered order
+var ErrDuplicateOrder = errors.New("duplicate order")
 
 // OrderManagerStatistics represents a statistics object
 type OrderManagerStatistics struct {
 	TotalOrderCount      int
 	TotalFoodWaitTime    int
 	TotalCourierWaitTime int
+	// FoodWaitTimes is the distribution of the time the picked up orders waited
+	// for their couriers
+	FoodWaitTimes WaitTimeSketch
+	// CourierWaitTimes is the distribution of the time the couriers waited for
+	// the orders they picked up
+	CourierWaitTimes WaitTimeSketch
+	// DiscardedOrderCount is the number of orders discarded from the shelves;
+	// they are not part of TotalOrderCount
+	DiscardedOrderCount int
+	// WastedOrderCount is the number of orders whose value reached zero before
+	// they were picked up; they are not part of TotalOrderCount
+	WastedOrderCount int
+	// CancelledOrderCount is the number of orders cancelled before they were
+	// picked up; they are not part of TotalOrderCount
+	CancelledOrderCount int
+	// TotalDeliveredValue is the sum of the values of the picked up orders
+	TotalDeliveredValue float64
+	// CookedOrderCount is the number of orders that started cooking
+	CookedOrderCount int
+	// TotalPrepQueueTime is the total time orders waited for a cook station
+	TotalPrepQueueTime int
+	// MaxPrepQueueTime is the longest time an order waited for a cook station
+	// (i.e. the worst prep-start delay)
+	MaxPrepQueueTime int
+	// CookStationUtilization is the fraction of time the limited cook stations
+	// spent cooking
+	CookStationUtilization float64
+	// DispatchedCourierCount is the number of couriers dispatched
+	DispatchedCourierCount int
+	// TotalCourierQueueTime is the total time orders waited for a free courier
+	// of the fleet to be dispatched
+	TotalCourierQueueTime int
+	// ReplacedCourierCount is the number of couriers replaced since they had not
+	// arrived at the kitchen in time (e.g. never showed up)
+	ReplacedCourierCount int
+	// ReassignedOrderCount is the number of picked up orders whose courier was
+	// replaced at least once
+	ReassignedOrderCount int
+	// TotalReassignedFoodWaitTime is the total time the food of those orders
+	// waited for its courier
+	TotalReassignedFoodWaitTime int
+	// UndeliveredOrderCount is the number of dispatched orders that were neither
+	// picked up nor thrown away when Wait gave up on them; they are not part of
+	// TotalOrderCount
+	UndeliveredOrderCount int
+	// TotalDeliveryDistance is the total distance in meters from the kitchen to
+	// the customers of the picked up orders (with a geographic model only)
+	TotalDeliveryDistance float64
+	// TotalPickupDistance is the total distance in meters from where the
+	// dispatched couriers set off to the kitchen (with a geographic model only)
+	TotalPickupDistance float64
+	// TripCount is the number of trips on which couriers left the kitchen with
+	// the orders they picked up; a trip carries several orders once they are
+	// stacked
+	TripCount int
+	// HeldOrderCount is the number of picked up orders that their courier held
+	// on to at the kitchen, waiting to stack more orders
+	HeldOrderCount int
+	// TotalHeldTime is the total time those orders were held
+	TotalHeldTime int
+	// TotalStackingPenalty is the freshness those orders lost while they were
+	// held; it is taken off TotalDeliveredValue
+	TotalStackingPenalty float64
 
 	mutex *sync.Mutex
 }
@@ -35,26 +109,232 @@ func (o *OrderManagerStatistics) GetAverageStatistics() (
 	return
 }
 
+// GetAverageDeliveredValue gets the average freshness of the picked up orders
+func (o *OrderManagerStatistics) GetAverageDeliveredValue() float64 {
+	if o == nil || o.TotalOrderCount == 0 {
+		return 0
+	}
+	return o.TotalDeliveredValue / float64(o.TotalOrderCount)
+}
+
+// GetAveragePrepQueueTime gets the average time orders waited for a cook station
+func (o *OrderManagerStatistics) GetAveragePrepQueueTime() float64 {
+	if o == nil || o.CookedOrderCount == 0 {
+		return 0
+	}
+	return float64(o.TotalPrepQueueTime) / float64(o.CookedOrderCount)
+}
+
+// GetAverageCourierQueueTime gets the average time orders waited for a free
+// courier of the fleet
+func (o *OrderManagerStatistics) GetAverageCourierQueueTime() float64 {
+	if o == nil || o.DispatchedCourierCount == 0 {
+		return 0
+	}
+	return float64(o.TotalCourierQueueTime) / float64(o.DispatchedCourierCount)
+}
+
+// GetAverageReassignedFoodWaitTime gets the average time the food of the
+// orders whose courier was replaced waited, to compare with the average food
+// wait time of all the orders
+func (o *OrderManagerStatistics) GetAverageReassignedFoodWaitTime() float64 {
+	if o == nil || o.ReassignedOrderCount == 0 {
+		return 0
+	}
+	return float64(o.TotalReassignedFoodWaitTime) / float64(o.ReassignedOrderCount)
+}
+
+// GetAverageDeliveryDistance gets the average distance in meters from the
+// kitchen to the customers of the picked up orders
+func (o *OrderManagerStatistics) GetAverageDeliveryDistance() float64 {
+	if o == nil || o.TotalOrderCount == 0 {
+		return 0
+	}
+	return o.TotalDeliveryDistance / float64(o.TotalOrderCount)
+}
+
+// GetAveragePickupDistance gets the average distance in meters from where the
+// dispatched couriers set off to the kitchen
+func (o *OrderManagerStatistics) GetAveragePickupDistance() float64 {
+	if o == nil || o.DispatchedCourierCount == 0 {
+		return 0
+	}
+	return o.TotalPickupDistance / float64(o.DispatchedCourierCount)
+}
+
+// GetAverageOrdersPerTrip gets the average number of orders a courier left the
+// kitchen with
+func (o *OrderManagerStatistics) GetAverageOrdersPerTrip() float64 {
+	if o == nil || o.TripCount == 0 {
+		return 0
+	}
+	return float64(o.TotalOrderCount) / float64(o.TripCount)
+}
+
+// GetAverageStackingPenalty gets the average freshness the picked up orders
+// lost while their courier held on to them, to compare with the average
+// delivered freshness
+func (o *OrderManagerStatistics) GetAverageStackingPenalty() float64 {
+	if o == nil || o.TotalOrderCount == 0 {
+		return 0
+	}
+	return o.TotalStackingPenalty / float64(o.TotalOrderCount)
+}
+
+// GetFoodWaitTimeSummary gets the distribution of the food wait times
+func (o *OrderManagerStatistics) GetFoodWaitTimeSummary() WaitTimeSummary {
+	if o == nil {
+		return GetWaitTimeSummary(nil)
+	}
+	return o.FoodWaitTimes.GetSummary()
+}
+
+// GetCourierWaitTimeSummary gets the distribution of the courier wait times
+func (o *OrderManagerStatistics) GetCourierWaitTimeSummary() WaitTimeSummary {
+	if o == nil {
+		return GetWaitTimeSummary(nil)
+	}
+	return o.CourierWaitTimes.GetSummary()
+}
+
+// getOrderManagerStatistics <private> gets empty statistics; the percentiles
+// of the wait times are exact if the records are kept, since every wait is
+// kept in memory then anyway
+func getOrderManagerStatistics(config Config) *OrderManagerStatistics {
+	stats := &OrderManagerStatistics{
+		mutex: &sync.Mutex{},
+	}
+	if config.KeepRecords {
+		stats.FoodWaitTimes = getExactWaitTimeSketch()
+		stats.CourierWaitTimes = getExactWaitTimeSketch()
+	}
+	return stats
+}
+
 func (o *OrderManagerStatistics) IncrementTotalOrderCount() {
 	o.mutex.Lock()
 	defer o.mutex.Unlock()
 	o.TotalOrderCount++
 }
 
+// IncrementTotalFoodWaitTime records the time a picked up order waited
 func (o *OrderManagerStatistics) IncrementTotalFoodWaitTime(byMs int) {
 	o.mutex.Lock()
 	defer o.mutex.Unlock()
 	o.TotalFoodWaitTime += byMs
+	o.FoodWaitTimes.Add(byMs)
 }
 
+// IncrementTotalCourierWaitTime records the time a courier waited for the
+// order it picked up
 func (o *OrderManagerStatistics) IncrementTotalCourierWaitTime(byMs int) {
 	o.mutex.Lock()
 	defer o.mutex.Unlock()
 	o.TotalCourierWaitTime += byMs
+	o.CourierWaitTimes.Add(byMs)
+}
+
+func (o *OrderManagerStatistics) IncrementDiscardedOrderCount() {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.DiscardedOrderCount++
+}
+
+func (o *OrderManagerStatistics) IncrementWastedOrderCount() {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.WastedOrderCount++
+}
+
+func (o *OrderManagerStatistics) IncrementCancelledOrderCount() {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.CancelledOrderCount++
+}
+
+func (o *OrderManagerStatistics) IncrementTotalDeliveredValue(by float64) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.TotalDeliveredValue += by
+}
+
+// AddPrepQueueTime records the time an order waited for a cook station
+func (o *OrderManagerStatistics) AddPrepQueueTime(ms int) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.CookedOrderCount++
+	o.TotalPrepQueueTime += ms
+	if ms > o.MaxPrepQueueTime {
+		o.MaxPrepQueueTime = ms
+	}
+}
+
+// AddCourierQueueTime records the time an order waited for a free courier
+func (o *OrderManagerStatistics) AddCourierQueueTime(ms int) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.DispatchedCourierCount++
+	o.TotalCourierQueueTime += ms
+}
+
+func (o *OrderManagerStatistics) IncrementReplacedCourierCount() {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.ReplacedCourierCount++
+}
+
+// AddReassignedFoodWaitTime records the time a picked up order whose courier
+// was replaced waited
+func (o *OrderManagerStatistics) AddReassignedFoodWaitTime(ms int) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.ReassignedOrderCount++
+	o.TotalReassignedFoodWaitTime += ms
+}
+
+func (o *OrderManagerStatistics) IncrementTotalDeliveryDistance(by float64) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.TotalDeliveryDistance += by
+}
+
+func (o *OrderManagerStatistics) IncrementTotalPickupDistance(by float64) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.TotalPickupDistance += by
+}
+
+func (o *OrderManagerStatistics) IncrementTripCount() {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.TripCount++
+}
+
+// AddHeldOrder records the time a picked up order was held at the kitchen,
+// and the freshness it lost meanwhile
+func (o *OrderManagerStatistics) AddHeldOrder(ms int, penalty float64) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.HeldOrderCount++
+	o.TotalHeldTime += ms
+	o.TotalStackingPenalty += penalty
+	o.TotalDeliveredValue -= penalty
+}
+
+func (o *OrderManagerStatistics) SetCookStationUtilization(utilization float64) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.CookStationUtilization = utilization
+}
+
+func (o *OrderManagerStatistics) SetUndeliveredOrderCount(count int) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.UndeliveredOrderCount = count
 }
 
 func (o *OrderManagerStatistics) ReportStatistics() {
-	if o == nil || o.TotalOrderCount == 0 {
+	if o == nil || o.TotalOrderCount+o.DiscardedOrderCount+o.WastedOrderCount+o.CancelledOrderCount+o.UndeliveredOrderCount == 0 {
 		log.Printf(
 			`
 			NO ORDERS HAVE BEEN PROCESSED. NO STATISTICS TO REPORT.
@@ -62,18 +342,55 @@ func (o *OrderManagerStatistics) ReportStatistics() {
 		)
 	} else {
 		avgFoodWaitTime, avgCourierWaitTime := o.GetAverageStatistics()
+		header := "[ALL ORDERS HAVE BEEN PROCESSED]"
+		if o.UndeliveredOrderCount > 0 {
+			header = fmt.Sprintf("[STOPPED WITH %d UNDELIVERED ORDER(S)]", o.UndeliveredOrderCount)
+		}
 		log.Printf(
 			`
 		***************************************************************
-		[ALL ORDERS HAVE BEEN PROCESSED]
+		%s
 		Total Order Count: %d order(s)
+		Discarded Order Count: %d order(s)
+		Wasted Order Count: %d order(s)
+		Cancelled Order Count: %d order(s)
 		Average Food Wait Time: %.4f ms
 		Average Courier Wait Time: %.4f ms
+		Food Wait Time: %s
+		Courier Wait Time: %s
+		Average Delivered Freshness: %.4f
+		Average Prep Queue Time: %.4f ms
+		Max Prep-Start Delay: %d ms
+		Cook Station Utilization: %.2f%%
+		Average Courier Queue Time: %.4f ms
+		Replaced Courier Count: %d courier(s)
+		Average Food Wait Time of Reassigned Orders: %.4f ms
+		Average Pickup Distance: %.1f m
+		Average Delivery Distance: %.1f m
+		Average Orders per Trip: %.2f
+		Average Freshness Lost to Stacking: %.4f
 		***************************************************************
 		`,
+			header,
 			o.TotalOrderCount,
+			o.DiscardedOrderCount,
+			o.WastedOrderCount,
+			o.CancelledOrderCount,
 			avgFoodWaitTime,
 			avgCourierWaitTime,
+			o.GetFoodWaitTimeSummary(),
+			o.GetCourierWaitTimeSummary(),
+			o.GetAverageDeliveredValue(),
+			o.GetAveragePrepQueueTime(),
+			o.MaxPrepQueueTime,
+			o.CookStationUtilization*100,
+			o.GetAverageCourierQueueTime(),
+			o.ReplacedCourierCount,
+			o.GetAverageReassignedFoodWaitTime(),
+			o.GetAveragePickupDistance(),
+			o.GetAverageDeliveryDistance(),
+			o.GetAverageOrdersPerTrip(),
+			o.GetAverageStackingPenalty(),
 		)
 
 	}
@@ -82,64 +399,485 @@ func (o *OrderManagerStatistics) ReportStatistics() {
 // OrderManager is a generic interface that performs dispatching of an order
 type OrderManager interface {
 	Init(random *rand.Rand)
-	DispatchOrder(order *resource.Order) error
-	Wait()
+	// DispatchOrder dispatches the order; returns the error of the context
+	// without dispatching it if the context is done, ErrNilOrder if there is no
+	// order, or ErrDuplicateOrder if an undelivered order has the same ID (the
+	// ID can be reused once the order is picked up or thrown away)
+	DispatchOrder(ctx context.Context, order *resource.Order) error
+	// Wait waits for all the dispatched orders to be picked up or thrown away.
+	// If the context is done first, the simulation is stopped (every goroutine
+	// it runs unwinds before Wait returns) and the error of the context is
+	// returned; the statistics then cover the orders finished so far
+	Wait(ctx context.Context) error
+	// CancelOrder cancels an order that has not been picked up yet: the order
+	// stops cooking (or comes off its shelf if it is prepared), and the courier
+	// on the way for it is recalled (or freed up if it is waiting). Returns
+	// ErrOrderNotFound if there is no such order
+	CancelOrder(orderID string) error
 	ReportStatistics()
 	GetStatistics() *OrderManagerStatistics
+	// GetUndeliveredOrders gets the dispatched orders that have not been picked
+	// up or thrown away yet, in the order they were dispatched
+	GetUndeliveredOrders() []*resource.Order
+	// GetRecords gets the records of the orders picked up so far, in the
+	// order they were picked up; there are none unless Config.KeepRecords is
+	// set
+	GetRecords() []resource.OrderRecord
+	// Subscribe registers a listener that is called with every event, in the
+	// order they happened, once the manager has released its lock (so that a
+	// slow listener does not hold up the matching, although it does hold up
+	// the goroutine delivering the events); the listener must not dispatch
+	// orders itself. Returns the function that unregisters the listener
+	Subscribe(listener Listener) (unsubscribe func())
+	// SubscribeChannel registers a buffered channel of the given size that
+	// receives every event from a goroutine of the manager, so that a
+	// subscriber that does not keep up never holds up the simulation; events
+	// are dropped while the channel is full. Wait returns once every event has
+	// been sent, unless its context is done first
+	SubscribeChannel(size int) *ChannelSubscription
+}
+
+// simulation is what the dispatched orders and couriers report back to once
+// they are done cooking, picking up, or delivering; both engines implement it
+// besides OrderManager, which other packages can therefore implement as well
+type simulation interface {
+	finishOrder(d *DispatchedOrder) error
+	finishPickUp(d *DispatchedCourier) error
+	finishDelivery(d *DispatchedCourier) error
+}
 
-	// private functions
-	finishOrder(d *dispatchedOrder) error
-	finishPickUp(d *dispatchedCourier) error
+// Config configures the kitchen simulated by an order manager; the zero value
+// simulates a kitchen without any limits
+type Config struct {
+	Shelves ShelfConfig
+	Kitchen KitchenConfig
+	Fleet   FleetConfig
+	Geo     GeoConfig
+	// Logger logs the events of the simulation; defaults to human-readable
+	// lines on the standard error
+	Logger EventLogger
+	// KeepRecords keeps the record of every picked up order for GetRecords,
+	// and every wait time for exact percentiles. They take memory for every
+	// order, so they are only kept when asked for
+	KeepRecords bool
 }
 
 type orderManagerBase struct {
-	mutex  *sync.RWMutex
-	wg     *sync.WaitGroup
-	random *rand.Rand
+	mutex    *sync.RWMutex
+	random   *rand.Rand
+	clock    clock.Clock
+	config   Config
+	strategy Strategy
+	shelves  *shelves
+	kitchen  *kitchen
+	fleet    *fleet
+	geo      *geography
+	events   *eventHub
+	finished int // orders finished since the lock was taken
 
-	stats *OrderManagerStatistics
-}
+	ctx     context.Context // done once the simulation is stopped
+	cancel  context.CancelFunc
+	running *sync.WaitGroup // goroutines started with spawn
 
-type matchedOrderManager struct {
-	*orderManagerBase
-	finishedOrderMap *sync.Map
-	courierMap       *sync.Map
+	pending         *list.List // undelivered orders in dispatch order (*DispatchedOrder)
+	pendingElements map[*DispatchedOrder]*list.Element
+	idle            chan struct{}               // closed while no order is pending
+	batching        bool                        // whether batches are scheduled (with a BatchStrategy)
+	pendingIDs      map[string]*DispatchedOrder // the pending orders by ID
+	holding         *list.List                  // couriers holding on to their orders for more (*DispatchedCourier)
+
+	stats   *OrderManagerStatistics
+	records []resource.OrderRecord
 }
 
-type fifoOrderManager struct {
+// concurrentOrderManager runs a goroutine for every order and courier
+type concurrentOrderManager struct {
 	*orderManagerBase
-	finishedOrderQueue *list.List
-	courierQueue       *list.List
 }
 
 // Init initializes the order manager instance
 func (o *orderManagerBase) Init(random *rand.Rand) {
 	o.random = random
-	o.stats = &OrderManagerStatistics{
-		mutex: &sync.Mutex{},
-	}
+	o.strategy.Init()
+	o.shelves = getShelves(o.config.Shelves, random)
+	o.kitchen = getKitchen(o.config.Kitchen)
+	o.geo = getGeography(o.config.Geo, random)
+	chooser, _ := o.strategy.(CourierChooser) // nil unless it chooses the couriers
+	o.fleet = getFleet(o.config.Fleet, o.geo, chooser)
+	o.stats = getOrderManagerStatistics(o.config)
+	o.records = nil
+	o.resetPending()
+}
+
+// resetPending <private> forgets about the dispatched orders, and gets a new
+// context for the goroutines of the simulation
+func (o *orderManagerBase) resetPending() {
+	o.ctx, o.cancel = context.WithCancel(context.Background())
+	o.pending = list.New()
+	o.pendingElements = map[*DispatchedOrder]*list.Element{}
+	o.pendingIDs = map[string]*DispatchedOrder{}
+	o.idle = make(chan struct{})
+	close(o.idle)
+	o.batching = false
+	o.holding = list.New()
 }
 
 func (o *orderManagerBase) lock() {
 	o.mutex.Lock()
 }
 
+// unlock <private> lets Wait know about the finished orders (and the couriers
+// that have left the kitchen with them), releases the lock, then delivers the
+// events emitted while holding it
 func (o *orderManagerBase) unlock() {
+	if o.finished > 0 && o.pending.Len() == 0 && o.holding.Len() == 0 {
+		select {
+		case <-o.idle:
+		default:
+			close(o.idle)
+		}
+	}
+	o.finished = 0
 	o.mutex.Unlock()
+	o.events.deliver()
+}
+
+// checkOrder <private> returns why the order cannot be dispatched, if it
+// cannot. Only the IDs of the pending orders are taken, so that the IDs take
+// memory for the orders in flight only. Must be called with the lock held
+func (o *orderManagerBase) checkOrder(order *resource.Order) error {
+	if order == nil {
+		return ErrNilOrder
+	}
+	if _, pending := o.pendingIDs[order.ID]; pending {
+		return fmt.Errorf("%w with ID %s", ErrDuplicateOrder, order.ID)
+	}
+	return nil
 }
 
-func (o *orderManagerBase) wgAdd() {
-	o.wg.Add(1)
+// addPending <private> marks an order as dispatched, until it is finished.
+// Must be called with the lock held
+func (o *orderManagerBase) addPending(order *DispatchedOrder) {
+	select {
+	case <-o.idle:
+		o.idle = make(chan struct{})
+	default:
+	}
+	o.pendingElements[order] = o.pending.PushBack(order)
+	o.pendingIDs[order.Order.ID] = order
 }
 
-func (o *orderManagerBase) wgDone() {
-	o.wg.Done()
+// donePending <private> marks an order as finished; Wait is told when
+// unlocking. Must be called with the lock held
+func (o *orderManagerBase) donePending(order *DispatchedOrder) {
+	if element, ok := o.pendingElements[order]; ok {
+		o.pending.Remove(element)
+		delete(o.pendingElements, order)
+		delete(o.pendingIDs, order.Order.ID)
+	}
+	o.finished++
 }
 
-// finishing order by decrementing waitgroup count and increasing order count
-func (o *orderManagerBase) completeOrder() {
+// finishing order by marking it as finished and increasing order count
+func (o *orderManagerBase) completeOrder(order *DispatchedOrder) {
 	o.stats.IncrementTotalOrderCount()
-	o.wgDone()
+	o.donePending(order)
+}
+
+// spawn <private> runs f in a new goroutine on the clock, with the context
+// that is done once the simulation is stopped
+func (o *orderManagerBase) spawn(f func(ctx context.Context)) {
+	o.running.Add(1)
+	ctx := o.ctx
+	o.clock.Go(func() {
+		defer o.running.Done()
+		f(ctx)
+	})
+}
+
+// logEvent <private> logs an event of the given type about the order and/or
+// the courier
+func (o *orderManagerBase) logEvent(
+	eventType EventType,
+	order *DispatchedOrder,
+	courier *DispatchedCourier,
+	now time.Time,
+) {
+	event := Event{
+		Type: eventType,
+		Time: now,
+	}
+	if order != nil {
+		event.OrderID = order.Order.ID
+		event.OrderName = order.Order.Name
+		event.PrepTime = order.Order.PrepTime
+		event.Shelf = order.Shelf
+	}
+	if courier != nil {
+		if order == nil { // the order the courier was dispatched for
+			event.OrderID = courier.Courier.OrderID
+		}
+		event.CourierID = courier.Courier.ID
+		event.TravelTime = courier.Courier.TravelTime
+	}
+	if eventType == EventPickedUp {
+		event.FoodWaitTime = order.getWaitTimeInMs()
+		event.CourierWaitTime = courier.getWaitTimeInMs()
+	}
+	o.events.emit(event)
+}
+
+// discardOrder <private> discards an order, which completes the order without
+// picking it up
+func (o *orderManagerBase) discardOrder(order *DispatchedOrder, now time.Time) {
+	o.logEvent(EventOrderDiscarded, order, nil, now)
+	o.stats.IncrementDiscardedOrderCount()
+	o.donePending(order)
+}
+
+// wasteOrder <private> throws away an order whose value has reached zero,
+// which completes the order without picking it up
+func (o *orderManagerBase) wasteOrder(order *DispatchedOrder, now time.Time) {
+	o.logEvent(EventOrderWasted, order, nil, now)
+	o.stats.IncrementWastedOrderCount()
+	o.donePending(order)
+}
+
+// expireOrders <private> throws away all the orders on the shelves whose value
+// has reached zero by now. Must be called with the lock held
+func (o *orderManagerBase) expireOrders(now time.Time) {
+	for _, expired := range o.shelves.expire(now) {
+		o.strategy.RemoveOrder(expired)
+		o.wasteOrder(expired, now)
+	}
+}
+
+// startCooking <private> starts cooking the dispatched order if a cook station
+// is free; returns whether it has started. Must be called with the lock held
+func (o *orderManagerBase) startCooking(order *DispatchedOrder, now time.Time) bool {
+	if !o.kitchen.startCooking(order, now) {
+		return false
+	}
+	o.logEvent(EventOrderReceived, order, nil, now)
+	o.stats.AddPrepQueueTime(order.getPrepQueueTimeInMs())
+	if batch, ok := o.strategy.(BatchStrategy); ok {
+		batch.OrderCooking(order)
+	}
+	return true
+}
+
+// finishCooking <private> frees up the cook station of the prepared order;
+// returns the queued order that starts cooking on it, if any. Must be called
+// with the lock held
+func (o *orderManagerBase) finishCooking(order *DispatchedOrder, now time.Time) *DispatchedOrder {
+	o.logEvent(EventOrderPrepared, order, nil, now)
+	return o.cookNext(o.kitchen.finishCooking(order, now), now)
+}
+
+// cookNext <private> records that the queued order (if any) has started
+// cooking on a freed up station; returns the order
+func (o *orderManagerBase) cookNext(next *DispatchedOrder, now time.Time) *DispatchedOrder {
+	if next != nil {
+		o.logEvent(EventOrderReceived, next, nil, now)
+		o.stats.AddPrepQueueTime(next.getPrepQueueTimeInMs())
+		if batch, ok := o.strategy.(BatchStrategy); ok {
+			batch.OrderCooking(next)
+		}
+	}
+	o.stats.SetCookStationUtilization(o.kitchen.getUtilization())
+	return next
+}
+
+// newCourier <private> draws the trip of the courier for the order; only the
+// couriers of a limited fleet need to deliver the order and come back. With a
+// geographic model, the trip comes from the distances instead: a new courier
+// sets off from a random location (a courier of the fleet from where it is once
+// it is dispatched), and delivers the order once it has picked it up
+func (o *orderManagerBase) newCourier(order *resource.Order) *resource.Courier {
+	var courier *resource.Courier
+	if o.geo.enabled() {
+		courier = resource.NewCourier(order.ID, 0)
+		if o.fleet.unlimited {
+			o.geo.setOff(courier, o.geo.randomLocation())
+		}
+	} else {
+		courier = resource.NewCourier(
+			order.ID,
+			resource.GetCourierTravelTime(o.random),
+		)
+		if !o.fleet.unlimited {
+			courier.DeliveryTime = resource.GetCourierTravelTime(o.random)
+			courier.ReturnTime = courier.DeliveryTime
+		}
+	}
+	if probability := o.config.Fleet.NoShowProbability; probability > 0 {
+		courier.NoShow = o.random.Float64() < probability
+	}
+	return courier
+}
+
+// locateCustomer <private> places the customer of the dispatched order, at
+// random unless the order has a customer location (with a geographic model only)
+func (o *orderManagerBase) locateCustomer(order *DispatchedOrder) {
+	if !o.geo.enabled() {
+		return
+	}
+	order.Customer = order.Order.Customer
+	if order.Customer == nil {
+		order.Customer = o.geo.randomLocation()
+	}
+}
+
+// locateCourier <private> gets where the courier is at the end of its trip
+// (nil without a geographic model): where it delivered the order it picked up,
+// where it set off from if it never showed up, or at the kitchen otherwise
+func (o *orderManagerBase) locateCourier(courier *DispatchedCourier) *resource.Location {
+	switch {
+	case !o.geo.enabled():
+		return nil
+	case len(courier.carried) > 0:
+		return courier.carried[len(courier.carried)-1].Customer
+	case courier.Courier.NoShow:
+		return courier.Courier.Location
+	}
+	kitchen := o.config.Geo.Kitchen
+	return &kitchen
+}
+
+func (o *orderManagerBase) getNoShowTimeout() time.Duration {
+	seconds := o.config.Fleet.NoShowTimeout
+	if seconds <= 0 {
+		seconds = DefaultNoShowTimeout + o.geo.getLongestTravelTime()
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// isLate <private> returns whether the courier is not going to arrive at the
+// kitchen before it times out; only those couriers need to be timed
+func (o *orderManagerBase) isLate(courier *DispatchedCourier) bool {
+	return courier.Courier.NoShow ||
+		time.Duration(courier.Courier.TravelTime)*time.Second > o.getNoShowTimeout()
+}
+
+// replaceCourier <private> replaces the courier who has not arrived in time by
+// a new one, unless its order no longer needs a courier; the courier is
+// recalled if it does arrive after all. Returns the replacement, and the
+// queued trip that the freed up courier is dispatched on if it never shows up,
+// if they are on the way. Must be called with the lock held
+func (o *orderManagerBase) replaceCourier(courier *DispatchedCourier, now time.Time) (
+	replacement *DispatchedCourier,
+	next *DispatchedCourier,
+) {
+	order := courier.order
+	if _, pending := o.pendingElements[order]; pending && !courier.recalled {
+		courier.recalled = true
+		o.strategy.RemoveCourier(courier)
+		o.logEvent(EventCourierReplaced, nil, courier, now)
+		o.stats.IncrementReplacedCourierCount()
+		order.Reassignments++
+		replacement = getDispatchedCourier(courier.manager, o.clock, o.newCourier(order.Order))
+		replacement.RequestedTime = now // the clock may be behind (e.g. discrete events)
+		replacement.order, order.courier = order, replacement
+		if !o.dispatchCourier(replacement, now) { // waits for a free courier
+			replacement = nil
+		}
+	} else if !courier.recalled { // the order has been thrown away
+		courier.recalled = true
+		o.strategy.RemoveCourier(courier)
+	}
+	if courier.Courier.NoShow { // free for the next trip, since it never arrives
+		next = o.releaseCourier(courier, now)
+	}
+	return replacement, next
+}
+
+// dispatchCourier <private> dispatches a free courier of the fleet on the
+// trip, if there is one; returns whether it has been dispatched. Must be
+// called with the lock held
+func (o *orderManagerBase) dispatchCourier(courier *DispatchedCourier, now time.Time) bool {
+	if !o.fleet.dispatch(courier, now) {
+		return false
+	}
+	o.logEvent(EventCourierDispatched, nil, courier, now)
+	o.stats.AddCourierQueueTime(courier.getQueueTimeInMs())
+	o.stats.IncrementTotalPickupDistance(courier.Courier.Distance)
+	if batch, ok := o.strategy.(BatchStrategy); ok {
+		batch.CourierDispatched(courier)
+	}
+	return true
+}
+
+// releaseCourier <private> frees up the courier of a finished trip; returns
+// the queued trip that the courier is dispatched on, if any. Must be called
+// with the lock held
+func (o *orderManagerBase) releaseCourier(courier *DispatchedCourier, now time.Time) *DispatchedCourier {
+	next := o.fleet.release(courier, o.locateCourier(courier), now)
+	if next != nil {
+		o.logEvent(EventCourierDispatched, nil, next, now)
+		o.stats.AddCourierQueueTime(next.getQueueTimeInMs())
+		o.stats.IncrementTotalPickupDistance(next.Courier.Distance)
+		if batch, ok := o.strategy.(BatchStrategy); ok {
+			batch.CourierDispatched(next)
+		}
+	}
+	return next
+}
+
+// matchOrder <private> finds the courier to pick up the prepared order (a
+// courier holding on to its orders for more if none is waiting), or places the
+// order on a shelf to wait for one. If the strategy dispatches the couriers
+// once the orders are ready, the courier of the waiting order is dispatched
+// then, and returned as the trip to start. Must be called with the lock held
+func (o *orderManagerBase) matchOrder(order *DispatchedOrder, now time.Time) (
+	courier *DispatchedCourier,
+	trip *DispatchedCourier,
+) {
+	o.expireOrders(now)
+	courier = o.strategy.OrderReady(order)
+	if courier == nil && o.holding.Len() > 0 { // stacked with the orders held the longest
+		o.strategy.RemoveOrder(order)
+		courier = o.holding.Front().Value.(*DispatchedCourier)
+	}
+	if courier != nil {
+		return courier, nil
+	}
+	waiting := true
+	for _, discarded := range o.shelves.place(order, now) {
+		o.strategy.RemoveOrder(discarded)
+		o.discardOrder(discarded, now)
+		waiting = waiting && discarded != order
+	}
+	if waiting && o.dispatchesOnReady() {
+		trip = order.courier
+		trip.RequestedTime = now
+		if !o.dispatchCourier(trip, now) { // waits for a free courier
+			trip = nil
+		}
+	}
+	return nil, trip
+}
+
+// dispatchesOnReady <private> returns whether the couriers are dispatched once
+// their orders are ready, rather than once the orders are dispatched
+func (o *orderManagerBase) dispatchesOnReady() bool {
+	strategy, ok := o.strategy.(ReadyDispatchStrategy)
+	return ok && strategy.DispatchOnReady()
+}
+
+// matchCourier <private> finds the order for the arrived courier to pick up
+// and takes it off its shelf; if there is none, returns whether the courier
+// waits for one. Must be called with the lock held
+func (o *orderManagerBase) matchCourier(courier *DispatchedCourier, now time.Time) (*DispatchedOrder, bool) {
+	if courier.recalled { // the order has been cancelled; turn back
+		return nil, false
+	}
+	o.logEvent(EventCourierArrived, nil, courier, now)
+	o.expireOrders(now)
+	order, wait := o.strategy.CourierArrived(courier)
+	if order != nil {
+		o.shelves.remove(order, now)
+	}
+	return order, wait
 }
 
 func (o *orderManagerBase) incrementTotalFoodWaitTime(byMs int) {
@@ -150,9 +888,264 @@ func (o *orderManagerBase) incrementTotalCourierWaitTime(byMs int) {
 	o.stats.IncrementTotalCourierWaitTime(byMs)
 }
 
-// Wait waits for order manager to be done
-func (o *orderManagerBase) Wait() {
-	o.wg.Wait()
+// pickUp <private> hands the prepared food over to the courier at the given
+// time, which completes the order. A courier who already carries orders stacks
+// it with them, and the courier on the way for it is recalled; returns the
+// queued trip that the recalled courier is dispatched on if it is freed up
+func (o *orderManagerBase) pickUp(
+	order *DispatchedOrder,
+	courier *DispatchedCourier,
+	now time.Time,
+) (next *DispatchedCourier) {
+	switch {
+	case courier.order == order:
+	case len(courier.carried) == 0: // the couriers swap the orders they were on the way for
+		other := order.courier
+		other.order, courier.order.courier = courier.order, other
+		courier.order, order.courier = order, courier
+	default: // stacked; the courier on the way for it is no longer needed
+		surplus := order.courier
+		order.courier = courier
+		next = o.recallCourier(surplus, now)
+	}
+	order.PickedUpTime = now
+	order.Value = order.getValue()
+	if len(courier.carried) == 0 { // the trip starts with the first order
+		courier.PickedUpTime = now
+		o.stats.IncrementTripCount()
+	}
+	courier.carried = append(courier.carried, order)
+	if o.geo.enabled() {
+		o.geo.deliver(courier.Courier, order.Customer)
+		o.stats.IncrementTotalDeliveryDistance(courier.Courier.DeliveryDistance)
+	}
+	o.logEvent(EventPickedUp, order, courier, now)
+	o.incrementTotalFoodWaitTime(order.getWaitTimeInMs())
+	o.incrementTotalCourierWaitTime(courier.getWaitTimeInMs())
+	o.stats.IncrementTotalDeliveredValue(order.Value)
+	if order.Reassignments > 0 {
+		o.stats.AddReassignedFoodWaitTime(order.getWaitTimeInMs())
+	}
+	if o.config.KeepRecords {
+		o.records = append(o.records, getOrderRecord(order, courier))
+	}
+	o.completeOrder(order)
+	return next
+}
+
+// getStackSize <private> gets the most orders a courier picks up in a visit to
+// the kitchen; a single one unless the strategy stacks orders
+func (o *orderManagerBase) getStackSize() int {
+	if _, ok := o.strategy.(StackingStrategy); !ok || o.config.Fleet.StackSize < 1 {
+		return 1
+	}
+	return o.config.Fleet.StackSize
+}
+
+// stackOrders <private> lets the courier who has just picked up an order pick
+// up the other waiting orders as well, up to the stack size. Returns whether
+// the courier leaves the kitchen, rather than holding on to its orders for
+// more, and the queued trips that the recalled couriers are dispatched on.
+// Must be called with the lock held
+func (o *orderManagerBase) stackOrders(courier *DispatchedCourier, now time.Time) (
+	leave bool,
+	next []*DispatchedCourier,
+) {
+	size := o.getStackSize()
+	for len(courier.carried) < size {
+		order := o.strategy.(StackingStrategy).NextOrder()
+		if order == nil {
+			break
+		}
+		o.shelves.remove(order, now)
+		if trip := o.pickUp(order, courier, now); trip != nil {
+			next = append(next, trip)
+		}
+	}
+	if len(courier.carried) >= size || o.config.Fleet.StackWait <= 0 {
+		return true, next
+	}
+	if courier.holding == nil {
+		courier.holding = o.holding.PushBack(courier)
+	}
+	return false, next
+}
+
+// getStackWait <private> gets the longest time a courier holds on to its
+// orders at the kitchen
+func (o *orderManagerBase) getStackWait() time.Duration {
+	return time.Duration(o.config.Fleet.StackWait) * time.Second
+}
+
+// stopHolding <private> stops the courier from holding on to its orders;
+// returns whether it was. Must be called with the lock held
+func (o *orderManagerBase) stopHolding(courier *DispatchedCourier) bool {
+	if courier.holding == nil {
+		return false
+	}
+	o.holding.Remove(courier.holding)
+	courier.holding = nil
+	o.finished++ // Wait is told once the courier has left
+	return true
+}
+
+// departCourier <private> sends the courier off from the kitchen with the
+// orders it has picked up. The orders it held on to have kept going stale
+// meanwhile (as on a shelf without a decay modifier), which is the freshness
+// penalty of stacking; and every stacked order takes another leg of the trip.
+// Must be called with the lock held
+func (o *orderManagerBase) departCourier(courier *DispatchedCourier, now time.Time) {
+	o.stopHolding(courier)
+	for _, order := range courier.carried {
+		if held := now.Sub(order.PickedUpTime); held > 0 {
+			value := order.Value
+			order.decayTime = order.PickedUpTime
+			order.decayUntil(now, 1)
+			order.Value = order.getValue()
+			o.stats.AddHeldOrder(int(held.Milliseconds()), value-order.Value)
+		}
+	}
+	if len(courier.carried) < 2 {
+		return
+	}
+	if o.geo.enabled() { // from one customer to the next
+		customers := make([]*resource.Location, 0, len(courier.carried))
+		for _, order := range courier.carried {
+			customers = append(customers, order.Customer)
+		}
+		o.geo.route(courier.Courier, customers)
+	} else if !o.fleet.unlimited {
+		for range courier.carried[1:] {
+			courier.Courier.DeliveryTime += resource.GetCourierTravelTime(o.random)
+		}
+	}
+}
+
+// startBatches <private> returns whether the batches of a BatchStrategy have
+// to be scheduled, since they are not yet; they are scheduled from then on.
+// Must be called with the lock held
+func (o *orderManagerBase) startBatches() bool {
+	if _, ok := o.strategy.(BatchStrategy); !ok || o.batching {
+		return false
+	}
+	o.batching = true
+	return true
+}
+
+// assignBatch <private> matches the orders and couriers of a BatchStrategy at
+// the end of a window; returns the pairs to pick up right away, whose orders
+// have been taken off their shelves. Must be called with the lock held
+func (o *orderManagerBase) assignBatch(now time.Time) ([]*DispatchedOrder, []*DispatchedCourier) {
+	o.expireOrders(now)
+	orders, couriers := o.strategy.(BatchStrategy).Assign(now)
+	for _, order := range orders {
+		o.shelves.remove(order, now)
+	}
+	return orders, couriers
+}
+
+// findPending <private> finds the undelivered order with the ID. Must be
+// called with the lock held
+func (o *orderManagerBase) findPending(orderID string) *DispatchedOrder {
+	return o.pendingIDs[orderID]
+}
+
+// cancelOrder <private> cancels an undelivered order, and recalls its courier;
+// returns the queued order that starts cooking on the freed up station and the
+// queued trip that the freed up courier is dispatched on, if any. Must be
+// called with the lock held
+func (o *orderManagerBase) cancelOrder(orderID string, now time.Time) (
+	nextOrder *DispatchedOrder,
+	nextCourier *DispatchedCourier,
+	err error,
+) {
+	o.expireOrders(now)
+	order := o.findPending(orderID)
+	if order == nil {
+		return nil, nil, fmt.Errorf("%w with ID %s", ErrOrderNotFound, orderID)
+	}
+	order.cancelled = true
+	o.strategy.RemoveOrder(order)
+	if order.Shelf != "" { // prepared, and waiting on a shelf
+		o.shelves.remove(order, now)
+	} else { // cooking, or waiting for a cook station
+		nextOrder = o.cookNext(o.kitchen.cancel(order, now), now)
+	}
+	courier := order.courier
+	nextCourier = o.recallCourier(courier, now)
+	o.logEvent(EventOrderCancelled, order, courier, now)
+	o.stats.IncrementCancelledOrderCount()
+	o.donePending(order)
+	return nextOrder, nextCourier, nil
+}
+
+// recallCourier <private> recalls the courier whose order no longer needs it:
+// it is taken out of the line for a free courier, freed up right away if it is
+// waiting, or freed up once it arrives otherwise. Returns the queued trip that
+// the freed up courier is dispatched on, if any. Must be called with the lock
+// held
+func (o *orderManagerBase) recallCourier(courier *DispatchedCourier, now time.Time) *DispatchedCourier {
+	if o.fleet.cancel(courier) { // it was yet to be dispatched
+		return nil
+	}
+	if o.strategy.RemoveCourier(courier) { // waiting; free right away
+		return o.releaseCourier(courier, now)
+	}
+	courier.recalled = true // on the way; free once it arrives
+	return nil
+}
+
+// Wait waits for order manager to be done, or for the context to be done
+func (o *orderManagerBase) Wait(ctx context.Context) error {
+	o.mutex.RLock()
+	idle := o.idle
+	o.mutex.RUnlock()
+	var err error
+	o.clock.Idle(func() {
+		select {
+		case <-idle:
+		case <-ctx.Done():
+			select {
+			case <-idle: // finished just in time
+			default:
+				err = ctx.Err()
+			}
+		}
+	})
+	if err != nil {
+		o.stop()
+		return err
+	}
+	o.flushEvents(ctx)
+	return nil
+}
+
+// flushEvents <private> waits until the listeners and the channels have been
+// given every event, or until the context is done
+func (o *orderManagerBase) flushEvents(ctx context.Context) {
+	o.clock.Idle(func() {
+		o.events.flush(ctx)
+	})
+}
+
+// stop <private> stops the simulation: the sleeping goroutines wake up and
+// unwind without finishing their orders. Returns once all of them have
+func (o *orderManagerBase) stop() {
+	o.cancel()
+	o.clock.Idle(o.running.Wait)
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+	o.stats.SetUndeliveredOrderCount(o.pending.Len())
+}
+
+func (o *orderManagerBase) GetUndeliveredOrders() []*resource.Order {
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+	orders := make([]*resource.Order, 0, o.pending.Len())
+	for element := o.pending.Front(); element != nil; element = element.Next() {
+		orders = append(orders, element.Value.(*DispatchedOrder).Order)
+	}
+	return orders
 }
 
 func (o *orderManagerBase) ReportStatistics() {
@@ -163,208 +1156,266 @@ func (o *orderManagerBase) GetStatistics() *OrderManagerStatistics {
 	return o.stats
 }
 
-// Init initializes matched order manager instance
-func (m *matchedOrderManager) Init(random *rand.Rand) {
-	m.orderManagerBase.Init(random)
-	m.finishedOrderMap = &sync.Map{}
-	m.courierMap = &sync.Map{}
+func (o *orderManagerBase) GetRecords() []resource.OrderRecord {
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+	return append([]resource.OrderRecord(nil), o.records...)
 }
 
-// Init initializes FIFO order manager instance
-func (f *fifoOrderManager) Init(random *rand.Rand) {
-	f.orderManagerBase.Init(random)
-	f.finishedOrderQueue.Init()
-	f.courierQueue.Init()
+func (o *orderManagerBase) Subscribe(listener Listener) func() {
+	return o.events.subscribe(listener)
 }
 
-// DispatchOrder dispatches order to the order manager (using matched strategy)
-func (m *matchedOrderManager) DispatchOrder(order *resource.Order) error {
-	m.wgAdd()
-	log.Printf(
-		`
-		===============================================================
-		[ORDER DISPATCHED] ID: %s 
-		Order Name:		%s	Preparation Time (s):	%d
-		===============================================================
-		`,
-		order.ID,
-		order.Name,
-		order.PrepTime,
-	)
-	dispatchedOrder := getDispatchedOrder(m, order)
-	dispatchedCourier := getDispatchedCourier(
-		m,
-		resource.NewCourier(
-			order.ID,
-			resource.GetCourierTravelTime(m.random),
-		),
-	)
-	go dispatchedOrder.processOrder()  // non-blocking
-	go dispatchedCourier.pickUpOrder() // non-blocking
-	return nil
+func (o *orderManagerBase) SubscribeChannel(size int) *ChannelSubscription {
+	return o.events.subscribeChannel(size)
 }
 
-// DispatchOrder dispatches order to the order manager (using FIFO strategy)
-func (f *fifoOrderManager) DispatchOrder(order *resource.Order) error {
-	f.wgAdd()
-	log.Printf(
-		`
-		===============================================================
-		[ORDER DISPATCHED] ID: %s 
-		Order Name:		%s	Preparation Time (s):	%d
-		===============================================================
-		`,
-		order.ID,
-		order.Name,
-		order.PrepTime,
-	)
-	dispatchedOrder := getDispatchedOrder(f, order)
-	dispatchedCourier := getDispatchedCourier(
-		f,
-		resource.NewCourier(
-			order.ID,
-			resource.GetCourierTravelTime(f.random),
-		),
-	)
-	go dispatchedOrder.processOrder()  // non-blocking
-	go dispatchedCourier.pickUpOrder() // non-blocking
+// DispatchOrder dispatches order to the order manager
+func (c *concurrentOrderManager) DispatchOrder(ctx context.Context, order *resource.Order) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.lock()
+	if err := c.checkOrder(order); err != nil {
+		c.unlock()
+		return err
+	}
+	dispatchedOrder := getDispatchedOrder(c, c.clock, order)
+	c.locateCustomer(dispatchedOrder)
+	c.addPending(dispatchedOrder)
+	batches := c.startBatches()
+	c.logEvent(EventOrderDispatched, dispatchedOrder, nil, dispatchedOrder.StartTime)
+	dispatchedCourier := getDispatchedCourier(c, c.clock, c.newCourier(order))
+	dispatchedOrder.courier, dispatchedCourier.order = dispatchedCourier, dispatchedOrder
+	now := c.clock.Now()
+	started := c.startCooking(dispatchedOrder, now)
+	// unless it is dispatched once the order is ready
+	dispatched := !c.dispatchesOnReady() && c.dispatchCourier(dispatchedCourier, now)
+	c.unlock()
+	if started { // otherwise the order waits for a cook station to free up
+		c.spawn(dispatchedOrder.processOrder) // non-blocking
+	}
+	if dispatched { // otherwise the order waits for a free courier
+		c.startTrip(dispatchedCourier) // non-blocking
+	}
+	if batches {
+		c.spawn(c.runBatches)
+	}
 	return nil
 }
 
-// finishOrder <private> finish order (food) for matched strategy
-func (m *matchedOrderManager) finishOrder(order *dispatchedOrder) error {
-	m.lock() // global lock to prevent deadlock for channel
-	m.finishedOrderMap.Store(order.Order.ID, order)
-	courier, ok := m.courierMap.Load(order.Order.ID)
-	m.unlock()
-	if ok { // finished, and waiting courier found (order GETS PICKED UP by courier)
-		order.PickedUpTime = time.Now()
-		courier.(*dispatchedCourier).notification <- order
-		defer m.completeOrder() // one order is processed, so decrement the event wait group by one
-	} else { // since courier is not found, wait in line
-		<-order.notification            // wait for courier to be ready
-		order.PickedUpTime = time.Now() // picked up
-	}
-	m.incrementTotalFoodWaitTime(order.getWaitTimeInMs())
-	return nil
+// runBatches <private> matches the orders and couriers of a BatchStrategy at
+// the end of every window, until no order is pending
+func (c *concurrentOrderManager) runBatches(ctx context.Context) {
+	window := c.strategy.(BatchStrategy).GetWindow()
+	for c.clock.SleepContext(ctx, window) == nil {
+		c.lock()
+		if c.pending.Len() == 0 { // scheduled again by the next dispatched order
+			c.batching = false
+			c.unlock()
+			return
+		}
+		now := c.clock.Now()
+		orders, couriers := c.assignBatch(now)
+		for i := range orders {
+			c.deliver(orders[i], couriers[i], now)
+		}
+		c.unlock()
+	}
 }
 
-// evictFromFinishedOrderQueue evicts an element from courier queue using FIFO strategy
-func (f *fifoOrderManager) evictFromFinishedOrderQueue(elem *list.Element) {
-	f.lock()
-	defer f.unlock()
-	f.finishedOrderQueue.Remove(elem) // evict self from the queue since order has ben picked up
-}
-
-// finishOrder <private> finish order (food) for FIFO strategy
-func (f *fifoOrderManager) finishOrder(order *dispatchedOrder) error {
-	var courier *dispatchedCourier
-	f.lock() // global lock to prevent deadlock for channel
-	elem := f.finishedOrderQueue.PushBack(order)
-	ok := f.courierQueue.Len() > 0
-	if ok {
-		courier = f.courierQueue.Remove(f.courierQueue.Front()).(*dispatchedCourier)
-	}
-	f.unlock()
-	if ok { // finished, and waiting courier found (order GETS PICKED UP by courier)
-		order.PickedUpTime = time.Now()
-		f.evictFromFinishedOrderQueue(elem) // picked up; evict
-		courier.notification <- order
-		defer f.completeOrder() // one order is processed, so decrement the event wait group by one
-	} else { // since courier is not found, wait in line
-		<-order.notification                // wait for courier to be ready
-		f.evictFromFinishedOrderQueue(elem) // picked up; evict
-		order.PickedUpTime = time.Now()     // picked up
-	}
-	f.incrementTotalFoodWaitTime(order.getWaitTimeInMs())
+// finishOrder <private> finish order (food)
+func (c *concurrentOrderManager) finishOrder(order *DispatchedOrder) error {
+	c.lock()             // global lock so that either the order or the courier finds the other
+	if order.cancelled { // the station has been freed up already
+		c.unlock()
+		return nil
+	}
+	now := c.clock.Now()
+	next := c.finishCooking(order, now)
+	courier, trip := c.matchOrder(order, now)
+	if courier != nil {
+		// finished, and waiting courier found (order GETS PICKED UP by courier)
+		c.deliver(order, courier, now)
+	}
+	c.unlock()
+	if next != nil { // the next order in line starts cooking
+		c.spawn(next.processOrder)
+	}
+	if trip != nil { // the courier dispatched for the order sets off
+		c.startTrip(trip)
+	}
 	return nil
 }
 
-// finishPickUp <private> finish pick-up (courier) for matched strategy
-func (m *matchedOrderManager) finishPickUp(courier *dispatchedCourier) error {
-	m.lock() // global lock to prevent deadlock for channel
-	m.courierMap.Store(courier.Courier.OrderID, courier)
-	order, ok := m.finishedOrderMap.Load(courier.Courier.OrderID)
-	m.unlock()
-	if ok { // arrived, and order found (courier PICKS UP the order)
-		courier.PickedUpTime = time.Now()
-		order.(*dispatchedOrder).notification <- courier
-		defer m.completeOrder() // one order is processed, so decrement the event wait group by one
-	} else {
-		order = <-courier.notification // wait for order to be ready
-		courier.PickedUpTime = time.Now()
+// finishPickUp <private> finish pick-up (courier)
+func (c *concurrentOrderManager) finishPickUp(courier *DispatchedCourier) error {
+	c.lock() // global lock so that either the order or the courier finds the other
+	now := c.clock.Now()
+	order, wait := c.matchCourier(courier, now)
+	var next *DispatchedCourier
+	if order != nil { // arrived, and order found (courier PICKS UP the order)
+		c.deliver(order, courier, now)
+	} else if !wait { // nothing to pick up; the courier is free right away
+		next = c.releaseCourier(courier, now)
+	}
+	c.unlock()
+	if next != nil {
+		c.startTrip(next)
 	}
-	dOrder := order.(*dispatchedOrder)
-	logPickUpEvent(dOrder, courier)
-	m.incrementTotalCourierWaitTime(courier.getWaitTimeInMs())
 	return nil
 }
 
-// evictFromCourierQueue evicts an element from courier queue using FIFO strategy
-func (f *fifoOrderManager) evictFromCourierQueue(elem *list.Element) {
-	f.lock()
-	defer f.unlock()
-	f.courierQueue.Remove(elem)
-}
-
-// finishPickUp <private> finish pick-up (courier) for FIFO strategy
-func (f *fifoOrderManager) finishPickUp(courier *dispatchedCourier) error {
-	var order *dispatchedOrder
-	f.lock() // global lock to prevent deadlock for channel
-	elem := f.courierQueue.PushBack(courier)
-	ok := f.finishedOrderQueue.Len() > 0
-	if ok {
-		order = f.finishedOrderQueue.Remove(f.finishedOrderQueue.Front()).(*dispatchedOrder)
-	}
-	f.unlock()
-	if ok { // arrived, and order found (courier PICKS UP the order)
-		courier.PickedUpTime = time.Now()
-		f.evictFromCourierQueue(elem) // picked up; evict
-		order.notification <- courier
-		defer f.completeOrder() // one order is processed, so decrement the event wait group by one
-	} else {
-		order = <-courier.notification // wait for order to be ready
-		f.evictFromCourierQueue(elem)  // picked up; evict
-		courier.PickedUpTime = time.Now()
+// CancelOrder cancels an order that has not been picked up yet
+func (c *concurrentOrderManager) CancelOrder(orderID string) error {
+	c.lock()
+	nextOrder, nextCourier, err := c.cancelOrder(orderID, c.clock.Now())
+	c.unlock()
+	if nextOrder != nil { // the next order in line starts cooking
+		c.spawn(nextOrder.processOrder)
 	}
-	logPickUpEvent(order, courier)
-	f.incrementTotalCourierWaitTime(courier.getWaitTimeInMs())
-	return nil
+	if nextCourier != nil { // the courier goes on the next trip in line
+		c.startTrip(nextCourier)
+	}
+	return err
 }
 
-func getOrderManagerBaseClass(random *rand.Rand) *orderManagerBase {
-	return &orderManagerBase{
-		random: random,
-		mutex:  &sync.RWMutex{},
-		wg:     &sync.WaitGroup{},
-		stats: &OrderManagerStatistics{
-			mutex: &sync.Mutex{},
-		},
+// startTrip <private> sends the dispatched courier to the kitchen (unless it
+// never shows up), and replaces it if it has not arrived in time
+func (c *concurrentOrderManager) startTrip(courier *DispatchedCourier) {
+	if !courier.Courier.NoShow {
+		c.spawn(courier.pickUpOrder) // non-blocking
+	}
+	if c.isLate(courier) {
+		c.spawn(func(ctx context.Context) {
+			if c.clock.SleepContext(ctx, c.getNoShowTimeout()) == nil {
+				c.timeOutCourier(courier)
+			}
+		})
 	}
 }
 
-// GetMatchedOrderManager gets the singleton instance of order manager that uses
-// assigned order strategy
-func GetMatchedOrderManager(random *rand.Rand) OrderManager {
-	if matchedOrderManagerInstance == nil {
-		matchedOrderManagerInstance = &matchedOrderManager{
-			orderManagerBase: getOrderManagerBaseClass(random),
-			finishedOrderMap: &sync.Map{},
-			courierMap:       &sync.Map{},
-		}
+// timeOutCourier <private> replaces the courier who has not arrived in time
+func (c *concurrentOrderManager) timeOutCourier(courier *DispatchedCourier) {
+	c.lock()
+	replacement, next := c.replaceCourier(courier, c.clock.Now())
+	c.unlock()
+	if replacement != nil {
+		c.startTrip(replacement)
+	}
+	if next != nil {
+		c.startTrip(next)
 	}
-	return matchedOrderManagerInstance
 }
 
-// GetFIFOOrderManager gets the singleton instance of order manager that uses
-// FIFO order strategy
-func GetFIFOOrderManager(random *rand.Rand) OrderManager {
-	if fifoOrderManagerInstance == nil {
-		fifoOrderManagerInstance = &fifoOrderManager{
-			orderManagerBase:   getOrderManagerBaseClass(random),
-			finishedOrderQueue: list.New(),
-			courierQueue:       list.New(),
-		}
+// deliver <private> picks up the order (and the other waiting orders, if the
+// couriers stack them), and sends the courier off unless it holds on to its
+// orders for more. Must be called with the lock held
+func (c *concurrentOrderManager) deliver(order *DispatchedOrder, courier *DispatchedCourier, now time.Time) {
+	holding := courier.holding != nil
+	next := c.pickUp(order, courier, now)
+	leave, recalled := c.stackOrders(courier, now)
+	if next != nil {
+		c.startTrip(next)
 	}
-	return fifoOrderManagerInstance
+	for _, trip := range recalled {
+		c.startTrip(trip)
+	}
+	if leave {
+		c.depart(courier, now)
+	} else if !holding { // leaves once the wait is over, unless it is full by then
+		c.spawn(func(ctx context.Context) {
+			if c.clock.SleepContext(ctx, c.getStackWait()) == nil {
+				c.lock()
+				if c.stopHolding(courier) {
+					c.depart(courier, c.clock.Now())
+				}
+				c.unlock()
+			}
+		})
+	}
+}
+
+// depart <private> sends the courier off with its orders; the courier of a
+// limited fleet delivers them and comes back. Must be called with the lock held
+func (c *concurrentOrderManager) depart(courier *DispatchedCourier, now time.Time) {
+	c.departCourier(courier, now)
+	if !c.fleet.unlimited {
+		c.spawn(courier.deliverOrder)
+	}
+}
+
+// finishDelivery <private> finish delivery (courier back from the customer)
+func (c *concurrentOrderManager) finishDelivery(courier *DispatchedCourier) error {
+	c.lock()
+	now := c.clock.Now()
+	c.logEvent(EventCourierReturned, nil, courier, now)
+	next := c.releaseCourier(courier, now)
+	c.unlock()
+	if next != nil { // the courier goes on the next trip in line
+		c.startTrip(next)
+	}
+	return nil
+}
+
+func getOrderManagerBaseClass(
+	random *rand.Rand,
+	clk clock.Clock,
+	config Config,
+	strategy Strategy,
+) *orderManagerBase {
+	logger := config.Logger
+	if logger == nil {
+		logger = NewEventLogger(os.Stderr, EventLogText)
+	}
+	events := getEventHub(clk)
+	events.subscribe(logger.Log)
+	geo := getGeography(config.Geo, random)
+	chooser, _ := strategy.(CourierChooser) // nil unless it chooses the couriers
+	base := &orderManagerBase{
+		random:   random,
+		clock:    clk,
+		config:   config,
+		strategy: strategy,
+		shelves:  getShelves(config.Shelves, random),
+		kitchen:  getKitchen(config.Kitchen),
+		geo:      geo,
+		fleet:    getFleet(config.Fleet, geo, chooser),
+		events:   events,
+		mutex:    &sync.RWMutex{},
+		running:  &sync.WaitGroup{},
+		stats:    getOrderManagerStatistics(config),
+	}
+	base.resetPending()
+	return base
+}
+
+func newConcurrentOrderManager(
+	random *rand.Rand,
+	clk clock.Clock,
+	config Config,
+	strategy Strategy,
+) *concurrentOrderManager {
+	return &concurrentOrderManager{
+		orderManagerBase: getOrderManagerBaseClass(random, clk, config, strategy),
+	}
+}
+
+// NewOrderManager constructs an order manager that runs a goroutine for every
+// order and courier on the given clock, and matches them with the given
+// strategy (e.g. one from GetStrategy)
+func NewOrderManager(random *rand.Rand, clk clock.Clock, config Config, strategy Strategy) OrderManager {
+	return newConcurrentOrderManager(random, clk, config, strategy)
+}
+
+// NewMatchedOrderManager constructs a new order manager that uses assigned
+// order strategy, running on the given clock
+func NewMatchedOrderManager(random *rand.Rand, clk clock.Clock, config Config) OrderManager {
+	return newConcurrentOrderManager(random, clk, config, getMatchedStrategy())
+}
+
+// NewFIFOOrderManager constructs a new order manager that uses FIFO order
+// strategy, running on the given clock
+func NewFIFOOrderManager(random *rand.Rand, clk clock.Clock, config Config) OrderManager {
+	return newConcurrentOrderManager(random, clk, config, getFIFOStrategy())
 }
@@ -1,12 +1,16 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"math/rand"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/suite"
+	"wonsoh.private/cloudkitchens/clock"
 	"wonsoh.private/cloudkitchens/mocks"
 	"wonsoh.private/cloudkitchens/resource"
 )
@@ -69,7 +73,13 @@ func (o *OrderManagerTestSuite) SetupTest() {
 }
 
 func (o *OrderManagerTestSuite) getMockRand() *rand.Rand {
-	mockRandSrc := mocks.NewMockSource(o.ctrl)
+	return getMockRand(o.ctrl)
+}
+
+// getMockRand gets a random number generator that generates the courier travel
+// times in testCourierTravelTimes, in order
+func getMockRand(ctrl *gomock.Controller) *rand.Rand {
+	mockRandSrc := mocks.NewMockSource(ctrl)
 	pointer := 0
 	mockRandSrc.EXPECT().Int63().AnyTimes().DoAndReturn(func() int64 {
 		n := len(testCourierTravelTimes)
@@ -80,6 +90,24 @@ func (o *OrderManagerTestSuite) getMockRand() *rand.Rand {
 	return rand.New(mockRandSrc)
 }
 
+// getScriptedRand gets a random number generator whose source generates the
+// given values, in order
+func getScriptedRand(ctrl *gomock.Controller, values ...int64) *rand.Rand {
+	mockRandSrc := mocks.NewMockSource(ctrl)
+	pointer := 0
+	mockRandSrc.EXPECT().Int63().AnyTimes().DoAndReturn(func() int64 {
+		value := values[pointer%len(values)]
+		pointer++
+		return value
+	})
+	return rand.New(mockRandSrc)
+}
+
+// travelTimeValue is the value of a random source that draws the travel time
+func travelTimeValue(travelTime int) int64 {
+	return int64(travelTime-resource.MinTravelTime) << 32
+}
+
 func (o *OrderManagerTestSuite) TestTravelTimeGeneration() {
 	random := o.getMockRand()
 	for _, travelTime := range testCourierTravelTimes {
@@ -89,18 +117,26 @@ func (o *OrderManagerTestSuite) TestTravelTimeGeneration() {
 
 func (o *OrderManagerTestSuite) TestOrderManagerBase() {
 	random := o.getMockRand()
-	base := getOrderManagerBaseClass(random)
-	base.wgAdd()
-	base.wgAdd()
+	base := getOrderManagerBaseClass(random, clock.GetRealClock(), Config{}, getMatchedStrategy())
+	first, second := &DispatchedOrder{Order: &resource.Order{ID: "1"}}, &DispatchedOrder{Order: &resource.Order{ID: "2"}}
+	base.lock()
+	base.addPending(first)
+	base.addPending(second)
+	base.unlock()
 	go func(b *orderManagerBase) {
-		defer b.completeOrder()
+		b.lock()
+		defer b.unlock() // tells Wait about the completed order
+		defer b.completeOrder(first)
 		b.incrementTotalFoodWaitTime(14)
 	}(base)
 	go (func(b *orderManagerBase) {
-		defer b.completeOrder()
+		b.lock()
+		defer b.unlock()
+		defer b.completeOrder(second)
 		b.incrementTotalCourierWaitTime(6)
 	})(base)
-	base.Wait()
+	o.NoError(base.Wait(context.Background()))
+	o.Empty(base.GetUndeliveredOrders())
 	stats := base.GetStatistics()
 	avgFoodWaitTime, avgCourierWaitTime := stats.GetAverageStatistics()
 	o.Equal(2, stats.TotalOrderCount)
@@ -124,13 +160,13 @@ func (o *OrderManagerTestSuite) TestMatchedOrderManager() {
 	// Food waits total of 4 seconds (avg 1000 ms)
 	// Courier waits total of 6 seconds (avg 750 ms)
 	random := o.getMockRand()
-	manager := GetMatchedOrderManager(random)
-	o.Equal(manager, GetMatchedOrderManager(random)) // test singleton
+	clk := clock.GetRealClock()
+	manager := NewMatchedOrderManager(random, clk, Config{})
+	o.NotSame(manager, NewMatchedOrderManager(random, clk, Config{})) // test fresh instances
 	for _, order := range testOrders {
-		o.NoError(manager.DispatchOrder(order))
+		o.NoError(manager.DispatchOrder(context.Background(), order))
 	}
-	manager.Wait()
-	time.Sleep(1 * time.Second)
+	o.NoError(manager.Wait(context.Background()))
 	o.NotPanics(func() {
 		manager.ReportStatistics()
 	})
@@ -166,13 +202,13 @@ func (o *OrderManagerTestSuite) TestFIFOOrderManager() {
 	// Food waits total of 1 second (avg 250 ms)
 	// Courier waits total of 3 seconds (avg 750 ms)
 	random := o.getMockRand()
-	manager := GetFIFOOrderManager(random)
-	o.Equal(manager, GetFIFOOrderManager(random)) // test singleton
+	clk := clock.GetRealClock()
+	manager := NewFIFOOrderManager(random, clk, Config{})
+	o.NotSame(manager, NewFIFOOrderManager(random, clk, Config{})) // test fresh instances
 	for _, order := range testOrders {
-		o.NoError(manager.DispatchOrder(order))
+		o.NoError(manager.DispatchOrder(context.Background(), order))
 	}
-	manager.Wait()
-	time.Sleep(1 * time.Second)
+	o.NoError(manager.Wait(context.Background()))
 	o.NotPanics(func() {
 		manager.ReportStatistics()
 	})
@@ -196,6 +232,658 @@ func (o *OrderManagerTestSuite) TestFIFOOrderManager() {
 	})
 }
 
+func (o *OrderManagerTestSuite) TestOrderManagersOnSimulatedClock() {
+	// same scenarios as above, but the waits are exact since no real time passes
+	for _, tc := range []struct {
+		name                 string
+		getManager           func(random *rand.Rand, clk clock.Clock, config Config) OrderManager
+		totalFoodWaitTime    int
+		totalCourierWaitTime int
+		maxFoodWaitTime      int
+		maxCourierWaitTime   int
+	}{
+		{
+			name:                 "matched",
+			getManager:           NewMatchedOrderManager,
+			totalFoodWaitTime:    4000,
+			totalCourierWaitTime: 6000,
+			maxFoodWaitTime:      2000,
+			maxCourierWaitTime:   5000,
+		},
+		{
+			name:                 "FIFO",
+			getManager:           NewFIFOOrderManager,
+			totalFoodWaitTime:    1000,
+			totalCourierWaitTime: 3000,
+			maxFoodWaitTime:      1000,
+			maxCourierWaitTime:   2000,
+		},
+	} {
+		start := time.Now()
+		clk := clock.GetSimulatedClock(start)
+		manager := tc.getManager(o.getMockRand(), clk, Config{KeepRecords: true})
+		for _, order := range testOrders {
+			o.NoError(manager.DispatchOrder(context.Background(), order))
+		}
+		o.NoError(manager.Wait(context.Background()))
+		o.Less(time.Since(start).Seconds(), float64(1), tc.name)
+		o.Equal(10*time.Second, clk.Now().Sub(start), tc.name) // the last order is ready at 10s
+		stats := manager.GetStatistics()
+		o.EqualValues(4, stats.TotalOrderCount, tc.name)
+		o.EqualValues(tc.totalFoodWaitTime, stats.TotalFoodWaitTime, tc.name)
+		o.EqualValues(tc.totalCourierWaitTime, stats.TotalCourierWaitTime, tc.name)
+		o.Equal(4, stats.GetFoodWaitTimeSummary().Count, tc.name)
+		o.Equal(4, stats.GetCourierWaitTimeSummary().Count, tc.name)
+		o.Equal(tc.maxFoodWaitTime, stats.GetFoodWaitTimeSummary().Max, tc.name)
+		o.Equal(tc.maxCourierWaitTime, stats.GetCourierWaitTimeSummary().Max, tc.name)
+		o.False(stats.GetFoodWaitTimeSummary().Approximate, tc.name) // since the records are kept
+		records := manager.GetRecords()
+		o.Len(records, 4, tc.name)
+		totalFoodWaitTime, totalCourierWaitTime := 0, 0
+		for _, record := range records {
+			o.False(record.PickedUpAt.Before(record.ReadyAt), tc.name)
+			o.False(record.PickedUpAt.Before(record.ArrivedAt), tc.name)
+			o.Equal(start, record.DispatchedAt, tc.name)
+			totalFoodWaitTime += record.FoodWaitTime
+			totalCourierWaitTime += record.CourierWaitTime
+		}
+		o.Equal(tc.totalFoodWaitTime, totalFoodWaitTime, tc.name)
+		o.Equal(tc.totalCourierWaitTime, totalCourierWaitTime, tc.name)
+	}
+}
+
+func (o *OrderManagerTestSuite) TestSideBySide() {
+	// managers share no state, so several simulations can run at the same time
+	getManagers := []func(random *rand.Rand, clk clock.Clock, config Config) OrderManager{
+		NewMatchedOrderManager,
+		NewFIFOOrderManager,
+		NewMatchedOrderManager,
+		NewFIFOOrderManager,
+	}
+	stats := make([]*OrderManagerStatistics, len(getManagers))
+	wg := &sync.WaitGroup{}
+	for i, getManager := range getManagers {
+		wg.Add(1)
+		go func(i int, manager OrderManager) {
+			defer wg.Done()
+			for _, order := range testOrders {
+				o.NoError(manager.DispatchOrder(context.Background(), order))
+			}
+			o.NoError(manager.Wait(context.Background()))
+			stats[i] = manager.GetStatistics()
+		}(i, getManager(o.getMockRand(), clock.GetSimulatedClock(time.Now()), Config{}))
+	}
+	wg.Wait()
+	for i, expected := range [][2]int{{4000, 6000}, {1000, 3000}, {4000, 6000}, {1000, 3000}} {
+		o.EqualValues(4, stats[i].TotalOrderCount, i)
+		o.EqualValues(expected[0], stats[i].TotalFoodWaitTime, i)
+		o.EqualValues(expected[1], stats[i].TotalCourierWaitTime, i)
+	}
+}
+
+// engineRun is a scenario that runOnEngines runs on both engines; the zero
+// value dispatches testOrders with the matched strategy, and the couriers
+// travel for testCourierTravelTimes
+type engineRun struct {
+	strategy string            // name of the strategy of each engine
+	random   func() *rand.Rand // constructs the random number generator of each engine
+	config   Config            // its Logger is replaced with a recordingEventLogger
+	orders   []*resource.Order
+	// before runs once the order manager is constructed, before the orders
+	// are dispatched
+	before func(name string, manager OrderManager)
+	// during runs once the orders are dispatched, before waiting for them
+	during func(name string, manager OrderManager, clk clock.Clock)
+}
+
+// runOnEngines runs the scenario on the concurrent engine, then on the event
+// engine, each on a simulated clock, and checks each order manager once all
+// the orders are finished
+func runOnEngines(
+	s *suite.Suite,
+	run engineRun,
+	check func(name string, manager OrderManager, logger *recordingEventLogger),
+) {
+	for _, engine := range []struct {
+		name       string
+		getManager func(random *rand.Rand, clk clock.Clock, config Config, strategy Strategy) OrderManager
+	}{
+		{name: "concurrent", getManager: NewOrderManager},
+		{name: "event", getManager: NewEventOrderManager},
+	} {
+		strategyName, random, orders := MatchedStrategyName, getMockRand(gomock.NewController(s.T())), testOrders
+		if run.strategy != "" {
+			strategyName = run.strategy
+		}
+		strategy, err := GetStrategy(strategyName)
+		s.Require().NoError(err)
+		if run.random != nil {
+			random = run.random()
+		}
+		if run.orders != nil {
+			orders = run.orders
+		}
+		logger := &recordingEventLogger{}
+		config := run.config
+		config.Logger = logger
+		clk := clock.GetSimulatedClock(time.Now())
+		manager := engine.getManager(random, clk, config, strategy)
+		if run.before != nil {
+			run.before(engine.name, manager)
+		}
+		for _, order := range orders {
+			s.NoError(manager.DispatchOrder(context.Background(), order), engine.name)
+		}
+		if run.during != nil {
+			run.during(engine.name, manager, clk)
+		}
+		s.NoError(manager.Wait(context.Background()), engine.name)
+		check(engine.name, manager, logger)
+	}
+}
+
+func (o *OrderManagerTestSuite) TestShelves() {
+	// Courier travel times are 4, 5, 3, and 8 seconds for orders A, B, C, and D.
+	// [1s] A is placed on the hot shelf, B on the overflow shelf, C on the cold shelf
+	// [2s] D discards B (the oldest on the overflow shelf) and takes its place
+	// [3s] Courier C picks up C (C waits 2 seconds)
+	// [4s] Courier A picks up A (A waits 3 seconds), and D moves up to the hot shelf
+	// [5s] Courier B leaves, since B has been discarded
+	// [8s] Courier D picks up D (D waits 6 seconds)
+	runOnEngines(&o.Suite, engineRun{
+		orders: []*resource.Order{
+			{ID: "A", Name: "Food A", PrepTime: 1, Temp: resource.TemperatureHot},
+			{ID: "B", Name: "Food B", PrepTime: 1, Temp: resource.TemperatureHot},
+			{ID: "C", Name: "Food C", PrepTime: 1, Temp: resource.TemperatureCold},
+			{ID: "D", Name: "Food D", PrepTime: 2, Temp: resource.TemperatureHot},
+		},
+		config: Config{
+			Shelves: ShelfConfig{
+				HotCapacity:      1,
+				ColdCapacity:     1,
+				OverflowCapacity: 1,
+			},
+		},
+	}, func(name string, manager OrderManager, _ *recordingEventLogger) {
+		stats := manager.GetStatistics()
+		o.EqualValues(3, stats.TotalOrderCount, name)
+		o.EqualValues(1, stats.DiscardedOrderCount, name)
+		o.EqualValues(11000, stats.TotalFoodWaitTime, name)
+		o.EqualValues(0, stats.TotalCourierWaitTime, name)
+		o.Empty(manager.GetRecords(), name) // only kept with KeepRecords
+	})
+}
+
+func (o *OrderManagerTestSuite) TestFreshness() {
+	// Courier travel times are 4, 5, 3, and 8 seconds for orders A, B, C, and D.
+	// [3s] A goes stale and gets wasted; C gets picked up fresh
+	// [4s] Courier A leaves, since A has been wasted
+	// [5s] B gets picked up after 4 seconds at double decay: 1 - 4 * 2 / 10 = 0.2
+	// [8s] D gets picked up after 7 seconds of its 100 seconds: 0.93
+	runOnEngines(&o.Suite, engineRun{
+		orders: []*resource.Order{
+			{ID: "A", Name: "Food A", PrepTime: 1, Temp: resource.TemperatureHot, ShelfLife: 2},
+			{ID: "B", Name: "Food B", PrepTime: 1, Temp: resource.TemperatureHot, ShelfLife: 10, DecayRate: 1},
+			{ID: "C", Name: "Food C", PrepTime: 3, Temp: resource.TemperatureHot},
+			{ID: "D", Name: "Food D", PrepTime: 1, Temp: resource.TemperatureHot, ShelfLife: 100},
+		},
+	}, func(name string, manager OrderManager, _ *recordingEventLogger) {
+		stats := manager.GetStatistics()
+		o.EqualValues(3, stats.TotalOrderCount, name)
+		o.EqualValues(1, stats.WastedOrderCount, name)
+		o.EqualValues(11000, stats.TotalFoodWaitTime, name)
+		o.InDelta(2.13, stats.TotalDeliveredValue, 1e-9, name)
+		o.InDelta(0.71, stats.GetAverageDeliveredValue(), 1e-9, name)
+	})
+}
+
+func (o *OrderManagerTestSuite) TestCookStations() {
+	// With a single cook station, the orders cook one after another:
+	// Food 1 [0s-2s] is picked up at 4s (food waits 2 seconds)
+	// Food 2 [2s-12s] is picked up right away (courier waits 7 seconds)
+	// Food 3 [12s-16s] is picked up right away (courier waits 13 seconds)
+	// Food 4 [16s-22s] is picked up right away (courier waits 14 seconds)
+	// The orders wait 0, 2, 12, and 16 seconds for the station
+	runOnEngines(&o.Suite, engineRun{
+		config: Config{Kitchen: KitchenConfig{CookStations: 1}},
+	}, func(name string, manager OrderManager, _ *recordingEventLogger) {
+		stats := manager.GetStatistics()
+		o.EqualValues(4, stats.TotalOrderCount, name)
+		o.EqualValues(2000, stats.TotalFoodWaitTime, name)
+		o.EqualValues(34000, stats.TotalCourierWaitTime, name)
+		o.EqualValues(4, stats.CookedOrderCount, name)
+		o.EqualValues(30000, stats.TotalPrepQueueTime, name)
+		o.EqualValues(16000, stats.MaxPrepQueueTime, name)
+		o.EqualValues(7500, stats.GetAveragePrepQueueTime(), name)
+		o.InDelta(1.0, stats.CookStationUtilization, 1e-9, name)
+	})
+}
+
+func (o *OrderManagerTestSuite) TestFleet() {
+	// With a single courier, which travels 4, 3, 4, and 3 seconds to the
+	// kitchen and 5, 8, 5, and 8 seconds to the customer (and as long back):
+	// Food 1 [0s-2s] is picked up at 4s, and the courier is back at 14s
+	// Food 2 [0s-10s] is picked up at 17s, and the courier is back at 33s
+	// Food 3 [0s-4s] is picked up at 37s, and the courier is back at 47s
+	// Food 4 [0s-6s] is picked up at 50s
+	// The orders wait 0, 14, 33, and 47 seconds for the courier
+	runOnEngines(&o.Suite, engineRun{
+		config: Config{Fleet: FleetConfig{Size: 1}},
+	}, func(name string, manager OrderManager, _ *recordingEventLogger) {
+		stats := manager.GetStatistics()
+		o.EqualValues(4, stats.TotalOrderCount, name)
+		o.EqualValues(86000, stats.TotalFoodWaitTime, name)
+		o.EqualValues(0, stats.TotalCourierWaitTime, name)
+		o.EqualValues(4, stats.DispatchedCourierCount, name)
+		o.EqualValues(94000, stats.TotalCourierQueueTime, name)
+		o.EqualValues(23500, stats.GetAverageCourierQueueTime(), name)
+	})
+}
+
+func (o *OrderManagerTestSuite) TestCancelOrder() {
+	// Courier travel times are 4, 5, 3, and 8 seconds for orders 1, 2, 3, and 4.
+	// [1s] 3 is cancelled while cooking; courier 3 is recalled on the way
+	// [4s] Courier 1 picks up 1 (1 waits 2 seconds)
+	// [5.5s] 2 is cancelled while cooking; courier 2 is waiting, and leaves
+	// [7s] 4 is cancelled on the shelf; courier 4 is recalled on the way
+	runOnEngines(&o.Suite, engineRun{
+		during: func(name string, manager OrderManager, clk clock.Clock) {
+			clk.Sleep(time.Second)
+			o.NoError(manager.CancelOrder("3"), name)
+			clk.Sleep(4500 * time.Millisecond)
+			o.NoError(manager.CancelOrder("2"), name)
+			clk.Sleep(1500 * time.Millisecond)
+			o.NoError(manager.CancelOrder("4"), name)
+			o.True(errors.Is(manager.CancelOrder("1"), ErrOrderNotFound), name) // picked up already
+			o.True(errors.Is(manager.CancelOrder("4"), ErrOrderNotFound), name)
+			o.True(errors.Is(manager.CancelOrder("5"), ErrOrderNotFound), name)
+		},
+	}, func(name string, manager OrderManager, logger *recordingEventLogger) {
+		stats := manager.GetStatistics()
+		o.EqualValues(1, stats.TotalOrderCount, name)
+		o.EqualValues(3, stats.CancelledOrderCount, name)
+		o.EqualValues(2000, stats.TotalFoodWaitTime, name)
+		o.EqualValues(0, stats.TotalCourierWaitTime, name)
+		o.Empty(manager.GetUndeliveredOrders(), name)
+		counts := logger.countTypes()
+		o.Equal(3, counts[EventOrderCancelled], name)
+		o.Equal(2, counts[EventOrderPrepared], name) // 1 and 4
+		o.Equal(2, counts[EventCourierArrived], name)
+	})
+}
+
+func (o *OrderManagerTestSuite) TestCancelOrderWithLimits() {
+	// With a single cook station and a single courier, cancelling the order
+	// being cooked frees up the station for the next one, and the courier on the
+	// way for it goes on the next trip in line once it gets to the kitchen
+	var stats []*OrderManagerStatistics
+	runOnEngines(&o.Suite, engineRun{
+		strategy: FIFOStrategyName,
+		config: Config{
+			Kitchen: KitchenConfig{CookStations: 1},
+			Fleet:   FleetConfig{Size: 1},
+		},
+		during: func(name string, manager OrderManager, clk clock.Clock) {
+			clk.Sleep(time.Second)
+			o.NoError(manager.CancelOrder("1"), name)
+			o.NoError(manager.CancelOrder("3"), name) // waiting for the station and the courier
+		},
+	}, func(name string, manager OrderManager, _ *recordingEventLogger) {
+		o.EqualValues(2, manager.GetStatistics().TotalOrderCount, name)
+		o.EqualValues(2, manager.GetStatistics().CancelledOrderCount, name)
+		o.EqualValues(3, manager.GetStatistics().CookedOrderCount, name) // 1, 2, and 4
+		stats = append(stats, manager.GetStatistics())
+	})
+	o.Equal(stats[0].FoodWaitTimes, stats[1].FoodWaitTimes)
+	o.Equal(stats[0].CourierWaitTimes, stats[1].CourierWaitTimes)
+	o.Equal(stats[0].TotalCourierQueueTime, stats[1].TotalCourierQueueTime)
+}
+
+func (o *OrderManagerTestSuite) TestLateCouriers() {
+	// Couriers who have not arrived 4 seconds after being dispatched are
+	// replaced; travel times are 4, 5, 3, and 8 seconds, then 4, 5, and 3
+	// seconds for the replacements.
+	// [4s] Courier 1 picks up 1 (1 waits 2 seconds)
+	// [4s] Couriers 2 and 4 are replaced by couriers 2' (4s) and 4' (5s)
+	// [8s] Courier 4' is replaced by courier 4'' (3s)
+	// [10s] Courier 2' picks up 2 (2' waits 2 seconds)
+	// [11s] Courier 4'' picks up 4 (4 waits 5 seconds)
+	runOnEngines(&o.Suite, engineRun{
+		config: Config{Fleet: FleetConfig{NoShowTimeout: 4}},
+	}, func(name string, manager OrderManager, logger *recordingEventLogger) {
+		stats := manager.GetStatistics()
+		o.EqualValues(4, stats.TotalOrderCount, name)
+		o.EqualValues(7000, stats.TotalFoodWaitTime, name)
+		o.EqualValues(3000, stats.TotalCourierWaitTime, name)
+		o.EqualValues(3, stats.ReplacedCourierCount, name)
+		o.EqualValues(2, stats.ReassignedOrderCount, name)
+		o.EqualValues(2500, stats.GetAverageReassignedFoodWaitTime(), name)
+		counts := logger.countTypes()
+		o.Equal(3, counts[EventCourierReplaced], name)
+		o.Equal(4, counts[EventCourierArrived], name) // the replaced couriers turn back
+	})
+}
+
+func (o *OrderManagerTestSuite) TestNoShow() {
+	// The first courier never shows up, and is replaced after 30 seconds by one
+	// who arrives 5 seconds later; a courier of the fleet who never shows up is
+	// free for the replacement trip right away
+	noShow, show := int64(0), int64(1<<62) // 0 and 0.5 as a Float64
+	for size, values := range map[int][]int64{
+		0: {travelTimeValue(4), noShow, travelTimeValue(5), show},
+		1: { // the delivery times are drawn as well
+			travelTimeValue(4),
+			travelTimeValue(5),
+			noShow,
+			travelTimeValue(5),
+			travelTimeValue(5),
+			show,
+		},
+	} {
+		runOnEngines(&o.Suite, engineRun{
+			random: func() *rand.Rand { return getScriptedRand(o.ctrl, values...) },
+			config: Config{Fleet: FleetConfig{Size: size, NoShowProbability: 0.5}},
+			orders: []*resource.Order{{ID: "1", Name: "Food 1", PrepTime: 2}},
+		}, func(name string, manager OrderManager, _ *recordingEventLogger) {
+			stats := manager.GetStatistics()
+			o.EqualValues(1, stats.TotalOrderCount, name, size)
+			o.EqualValues(33000, stats.TotalFoodWaitTime, name, size)
+			o.EqualValues(1, stats.ReplacedCourierCount, name, size)
+			o.EqualValues(33000, stats.TotalReassignedFoodWaitTime, name, size)
+			o.EqualValues(0, stats.TotalCourierQueueTime, name, size)
+		})
+	}
+}
+
+func (o *OrderManagerTestSuite) TestGeography() {
+	// The kitchen is at (0, 0), and couriers travel 10 meters per second.
+	// Courier 1 sets off from (50, -50) and travels 100 m in 10 seconds; 1 waits
+	// 8 seconds, and is delivered 70 m away to (30, 40).
+	// Customer 2 is placed at (50, 50), and courier 2 sets off from the kitchen
+	// itself; courier 2 waits 3 seconds, and delivers 2 100 m away
+	quarter, half, threeQuarters := int64(1<<61), int64(1<<62), int64(3<<61) // as a Float64
+	runOnEngines(&o.Suite, engineRun{
+		random: func() *rand.Rand {
+			return getScriptedRand(o.ctrl, threeQuarters, quarter, threeQuarters, threeQuarters, half, half)
+		},
+		config: Config{Geo: GeoConfig{Radius: 100, Speed: 10}, KeepRecords: true},
+		orders: []*resource.Order{
+			{ID: "1", Name: "Food 1", PrepTime: 2, Customer: &resource.Location{X: 30, Y: 40}},
+			{ID: "2", Name: "Food 2", PrepTime: 3},
+		},
+	}, func(name string, manager OrderManager, _ *recordingEventLogger) {
+		stats := manager.GetStatistics()
+		o.EqualValues(2, stats.TotalOrderCount, name)
+		o.EqualValues(8000, stats.TotalFoodWaitTime, name)
+		o.EqualValues(3000, stats.TotalCourierWaitTime, name)
+		o.EqualValues(170, stats.TotalDeliveryDistance, name)
+		o.EqualValues(85, stats.GetAverageDeliveryDistance(), name)
+		records := manager.GetRecords() // 2 is picked up first
+		o.Equal([]int{0, 10}, []int{records[0].TravelTime, records[1].TravelTime}, name)
+	})
+}
+
+func (o *OrderManagerTestSuite) TestGeographyWithFleet() {
+	// The only courier of the fleet starts off at (50, -50), 10 seconds away
+	// from the kitchen: it picks up 1 at 10s (1 waits 8 seconds), and delivers
+	// it to (30, 40) at 17s. It then sets off to the kitchen from there for 2,
+	// and picks it up at 24s (2 waits 21 seconds)
+	quarter, threeQuarters := int64(1<<61), int64(3<<61) // as a Float64
+	runOnEngines(&o.Suite, engineRun{
+		random: func() *rand.Rand { return getScriptedRand(o.ctrl, threeQuarters, quarter) },
+		config: Config{
+			Fleet: FleetConfig{Size: 1},
+			Geo:   GeoConfig{Radius: 100, Speed: 10},
+		},
+		orders: []*resource.Order{
+			{ID: "1", Name: "Food 1", PrepTime: 2, Customer: &resource.Location{X: 30, Y: 40}},
+			{ID: "2", Name: "Food 2", PrepTime: 3, Customer: &resource.Location{X: -30, Y: -40}},
+		},
+	}, func(name string, manager OrderManager, _ *recordingEventLogger) {
+		stats := manager.GetStatistics()
+		o.EqualValues(2, stats.TotalOrderCount, name)
+		o.EqualValues(29000, stats.TotalFoodWaitTime, name)
+		o.EqualValues(0, stats.TotalCourierWaitTime, name)
+		o.EqualValues(17000, stats.TotalCourierQueueTime, name)
+		o.EqualValues(140, stats.TotalDeliveryDistance, name)
+	})
+}
+
+func (o *OrderManagerTestSuite) TestNearestCourier() {
+	// The couriers of the fleet start off at (50, 50) and (0, 50), 10 and 5
+	// seconds away from the kitchen. FIFO dispatches the one that has been free
+	// the longest (the first) right away, and the nearest strategy the closest
+	// one once the food is ready at 2s
+	half, threeQuarters := int64(1<<62), int64(3<<61) // as a Float64
+	for strategyName, expected := range map[string]struct {
+		foodWaitTime   int
+		pickupDistance float64
+	}{
+		FIFOStrategyName:    {8000, 100},
+		NearestStrategyName: {5000, 50},
+	} {
+		runOnEngines(&o.Suite, engineRun{
+			strategy: strategyName,
+			random: func() *rand.Rand {
+				return getScriptedRand(o.ctrl, threeQuarters, threeQuarters, half, threeQuarters)
+			},
+			config: Config{
+				Fleet: FleetConfig{Size: 2},
+				Geo:   GeoConfig{Radius: 100, Speed: 10},
+			},
+			orders: []*resource.Order{
+				{ID: "1", Name: "Food 1", PrepTime: 2, Customer: &resource.Location{X: 30, Y: 40}},
+			},
+		}, func(name string, manager OrderManager, _ *recordingEventLogger) {
+			stats := manager.GetStatistics()
+			o.EqualValues(1, stats.TotalOrderCount, strategyName, name)
+			o.EqualValues(expected.foodWaitTime, stats.TotalFoodWaitTime, strategyName, name)
+			o.EqualValues(expected.pickupDistance, stats.GetAveragePickupDistance(), strategyName, name)
+		})
+	}
+}
+
+func (o *OrderManagerTestSuite) TestNearestCourierOnReady() {
+	// The couriers of the fleet start off at (50, 50) and (0, 50), 10 and 5
+	// seconds away from the kitchen. FIFO dispatches both right away: the
+	// second picks up 1 at 5s, and the first waits for 2 from 10s to 20s.
+	// The nearest strategy dispatches the second once 1 is ready at 2s; it
+	// delivers 1 at 8s 10 m away, and is the closest courier once 2 is ready
+	// at 20s, so it picks up both
+	half, threeQuarters := int64(1<<62), int64(3<<61) // as a Float64
+	for strategyName, expected := range map[string]struct {
+		foodWaitTime    int
+		courierWaitTime int
+		pickupDistance  float64
+		sameCourier     bool
+	}{
+		FIFOStrategyName:    {3000, 10000, 75, false},
+		NearestStrategyName: {6000, 0, 30, true},
+	} {
+		runOnEngines(&o.Suite, engineRun{
+			strategy: strategyName,
+			random: func() *rand.Rand {
+				return getScriptedRand(o.ctrl, threeQuarters, threeQuarters, half, threeQuarters)
+			},
+			config: Config{
+				Fleet:       FleetConfig{Size: 2},
+				Geo:         GeoConfig{Radius: 100, Speed: 10},
+				KeepRecords: true,
+			},
+			orders: []*resource.Order{
+				{ID: "1", Name: "Food 1", PrepTime: 2, Customer: &resource.Location{X: 0, Y: 10}},
+				{ID: "2", Name: "Food 2", PrepTime: 20, Customer: &resource.Location{X: 30, Y: 40}},
+			},
+		}, func(name string, manager OrderManager, _ *recordingEventLogger) {
+			stats := manager.GetStatistics()
+			o.EqualValues(2, stats.TotalOrderCount, strategyName, name)
+			o.EqualValues(expected.foodWaitTime, stats.TotalFoodWaitTime, strategyName, name)
+			o.EqualValues(expected.courierWaitTime, stats.TotalCourierWaitTime, strategyName, name)
+			o.EqualValues(expected.pickupDistance, stats.GetAveragePickupDistance(), strategyName, name)
+			records := manager.GetRecords()
+			o.Require().Len(records, 2, strategyName, name)
+			o.Equal(expected.sameCourier, records[0].CourierID == records[1].CourierID, strategyName, name)
+		})
+	}
+}
+
+func (o *OrderManagerTestSuite) TestBatch() {
+	// FIFO never lets the food and a courier wait at the same time, so the
+	// optimal batches make them wait as long in all as FIFO does (4 seconds;
+	// see testCourierTravelTimes)
+	for _, strategyName := range []string{FIFOStrategyName, BatchStrategyName} {
+		runOnEngines(&o.Suite, engineRun{
+			strategy: strategyName,
+		}, func(name string, manager OrderManager, _ *recordingEventLogger) {
+			stats := manager.GetStatistics()
+			o.EqualValues(4, stats.TotalOrderCount, strategyName, name)
+			o.EqualValues(4000, stats.TotalFoodWaitTime+stats.TotalCourierWaitTime, strategyName, name)
+		})
+	}
+}
+
+func (o *OrderManagerTestSuite) TestStacking() {
+	// All the food is prepared at 1s, and the couriers stack two orders each.
+	// Without a fleet, couriers 3 and 1 arrive at 3s and 4s, and each picks up
+	// two orders; couriers 2 and 4 are recalled on the way. With a fleet of two,
+	// the couriers of 1 and 2 arrive at 4s and 3s: the latter picks up 1 and 2,
+	// and the former is recalled, then sent for 3 and 4, which it picks up at 8s
+	for size, foodWaitTime := range map[int]int{0: 10000, 2: 18000} {
+		runOnEngines(&o.Suite, engineRun{
+			strategy: FIFOStrategyName,
+			config:   Config{Fleet: FleetConfig{Size: size, StackSize: 2}},
+			orders: []*resource.Order{
+				{ID: "1", Name: "Food 1", PrepTime: 1},
+				{ID: "2", Name: "Food 2", PrepTime: 1},
+				{ID: "3", Name: "Food 3", PrepTime: 1},
+				{ID: "4", Name: "Food 4", PrepTime: 1},
+			},
+		}, func(name string, manager OrderManager, _ *recordingEventLogger) {
+			stats := manager.GetStatistics()
+			o.EqualValues(4, stats.TotalOrderCount, name, size)
+			o.EqualValues(2, stats.TripCount, name, size)
+			o.EqualValues(2, stats.GetAverageOrdersPerTrip(), name, size)
+			o.EqualValues(foodWaitTime, stats.TotalFoodWaitTime, name, size)
+			o.EqualValues(0, stats.TotalCourierWaitTime, name, size)
+			o.EqualValues(0, stats.HeldOrderCount, name, size)
+		})
+	}
+}
+
+func (o *OrderManagerTestSuite) TestStackWait() {
+	// Courier A picks up A at 4s (A waits 3 seconds, and is 0.7 fresh), and
+	// holds on to it for up to 2 seconds. B is prepared at 5s and stacked, so
+	// the courier leaves at once with both; A has lost another 0.1 meanwhile.
+	// Courier B is recalled on the way
+	run := engineRun{
+		strategy: FIFOStrategyName,
+		random: func() *rand.Rand {
+			return getScriptedRand(o.ctrl, travelTimeValue(4), travelTimeValue(10))
+		},
+		config: Config{Fleet: FleetConfig{StackSize: 2, StackWait: 2}},
+		orders: []*resource.Order{
+			{ID: "A", Name: "Food A", PrepTime: 1, Temp: resource.TemperatureHot, ShelfLife: 10},
+			{ID: "B", Name: "Food B", PrepTime: 5, Temp: resource.TemperatureHot},
+		},
+	}
+	runOnEngines(&o.Suite, run, func(name string, manager OrderManager, _ *recordingEventLogger) {
+		stats := manager.GetStatistics()
+		o.EqualValues(2, stats.TotalOrderCount, name)
+		o.EqualValues(1, stats.TripCount, name)
+		o.EqualValues(3000, stats.TotalFoodWaitTime, name)
+		o.EqualValues(1, stats.HeldOrderCount, name)
+		o.EqualValues(1000, stats.TotalHeldTime, name)
+		o.InDelta(0.1, stats.TotalStackingPenalty, 1e-9, name)
+		o.InDelta(0.05, stats.GetAverageStackingPenalty(), 1e-9, name)
+		o.InDelta(1.6, stats.TotalDeliveredValue, 1e-9, name)
+	})
+
+	// without B, the courier leaves once the wait is over
+	run.orders = run.orders[:1]
+	runOnEngines(&o.Suite, run, func(name string, manager OrderManager, _ *recordingEventLogger) {
+		stats := manager.GetStatistics()
+		o.EqualValues(1, stats.TripCount, name)
+		o.EqualValues(2000, stats.TotalHeldTime, name)
+		o.InDelta(0.5, stats.TotalDeliveredValue, 1e-9, name)
+	})
+}
+
+func (o *OrderManagerTestSuite) TestDispatchInvalidOrder() {
+	runOnEngines(&o.Suite, engineRun{
+		orders: testOrders[:1],
+		during: func(name string, manager OrderManager, _ clock.Clock) {
+			o.ErrorIs(manager.DispatchOrder(context.Background(), nil), ErrNilOrder, name)
+			duplicate := *testOrders[0]
+			err := manager.DispatchOrder(context.Background(), &duplicate)
+			o.ErrorIs(err, ErrDuplicateOrder, name)
+			o.EqualError(err, "duplicate order with ID 1", name)
+		},
+	}, func(name string, manager OrderManager, _ *recordingEventLogger) {
+		o.EqualValues(1, manager.GetStatistics().TotalOrderCount, name) // a single courier for it
+
+		// the ID can be dispatched again once its order is picked up
+		o.NoError(manager.DispatchOrder(context.Background(), testOrders[0]), name)
+		o.NoError(manager.Wait(context.Background()), name)
+		o.EqualValues(2, manager.GetStatistics().TotalOrderCount, name)
+	})
+}
+
+func (o *OrderManagerTestSuite) TestReusedID() {
+	// Courier travel times are 3, 8, 3, and 10 seconds for X, A, Y, and the new A.
+	// [1s] X is placed on the hot shelf, and A on the overflow shelf
+	// [2s] Y discards A (the oldest on the overflow shelf) and takes its place
+	// [2.5s] A new order A is dispatched, since A is no longer pending
+	// [3s] Courier X picks up X, and courier Y picks up Y
+	// [3.5s] The new A is placed on the hot shelf
+	// [8s] Courier A turns back, since its own order is gone
+	// [12.5s] The courier of the new A picks it up (the new A waits 9 seconds)
+	runOnEngines(&o.Suite, engineRun{
+		random: func() *rand.Rand {
+			return getScriptedRand(o.ctrl, travelTimeValue(3), travelTimeValue(8), travelTimeValue(3), travelTimeValue(10))
+		},
+		config: Config{Shelves: ShelfConfig{HotCapacity: 1, OverflowCapacity: 1}},
+		orders: []*resource.Order{
+			{ID: "X", Name: "Food X", PrepTime: 1, Temp: resource.TemperatureHot},
+			{ID: "A", Name: "Food A", PrepTime: 1, Temp: resource.TemperatureHot},
+			{ID: "Y", Name: "Food Y", PrepTime: 2, Temp: resource.TemperatureHot},
+		},
+		during: func(name string, manager OrderManager, clk clock.Clock) {
+			clk.Sleep(2500 * time.Millisecond)
+			reused := &resource.Order{ID: "A", Name: "Food A", PrepTime: 1, Temp: resource.TemperatureHot}
+			o.NoError(manager.DispatchOrder(context.Background(), reused), name)
+		},
+	}, func(name string, manager OrderManager, _ *recordingEventLogger) {
+		stats := manager.GetStatistics()
+		o.EqualValues(3, stats.TotalOrderCount, name)
+		o.EqualValues(1, stats.DiscardedOrderCount, name)
+		o.EqualValues(2000+1000+9000, stats.TotalFoodWaitTime, name)
+		o.EqualValues(0, stats.TotalCourierWaitTime, name)
+	})
+}
+
+func (o *OrderManagerTestSuite) TestWaitCancelled() {
+	// the orders take seconds to prepare, so none is finished by the deadline
+	manager := NewFIFOOrderManager(o.getMockRand(), clock.GetRealClock(), Config{Fleet: FleetConfig{Size: 2}})
+	for _, order := range testOrders {
+		o.NoError(manager.DispatchOrder(context.Background(), order))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	o.Equal(context.DeadlineExceeded, manager.Wait(ctx))
+	o.Less(time.Since(start).Seconds(), float64(1)) // the goroutines unwound without sleeping on
+	o.Equal(testOrders, manager.GetUndeliveredOrders())
+	stats := manager.GetStatistics()
+	o.EqualValues(0, stats.TotalOrderCount)
+	o.EqualValues(len(testOrders), stats.UndeliveredOrderCount)
+	o.Empty(manager.GetRecords())
+	o.NotPanics(func() {
+		manager.ReportStatistics()
+	})
+	o.Equal(context.DeadlineExceeded, manager.DispatchOrder(ctx, testOrders[0]))
+	o.Len(manager.GetUndeliveredOrders(), len(testOrders))
+}
+
 func TestOrderManagerTestSuite(t *testing.T) {
 	suite.Run(t, new(OrderManagerTestSuite))
 }
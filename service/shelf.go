@@ -0,0 +1,197 @@
+package service
+
+import (
+	"container/list"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"wonsoh.private/cloudkitchens/resource"
+)
+
+const (
+	// OverflowShelf is the name of the shelf that takes food of any temperature
+	OverflowShelf = "overflow"
+	// OverflowDecayModifier is how much faster food decays on the overflow shelf
+	OverflowDecayModifier = 2
+	// TemperatureDecayModifier is how fast food decays on the shelf for its temperature
+	TemperatureDecayModifier = 1
+)
+
+// DiscardPolicy decides which order is discarded when the overflow shelf is full
+type DiscardPolicy string
+
+const (
+	// DiscardOldest discards the order that has been on the overflow shelf the longest
+	DiscardOldest DiscardPolicy = "oldest"
+	// DiscardNewest discards the order that is being placed
+	DiscardNewest DiscardPolicy = "newest"
+	// DiscardRandom discards a random order from the overflow shelf
+	DiscardRandom DiscardPolicy = "random"
+)
+
+// ShelfConfig configures the shelves prepared food waits on; a capacity of
+// zero (or less) means that the shelf is unlimited
+type ShelfConfig struct {
+	HotCapacity      int
+	ColdCapacity     int
+	FrozenCapacity   int
+	OverflowCapacity int
+	// DiscardPolicy defaults to DiscardOldest
+	DiscardPolicy DiscardPolicy
+}
+
+// ParseDiscardPolicy parses the name of a discard policy
+func ParseDiscardPolicy(name string) (DiscardPolicy, error) {
+	switch policy := DiscardPolicy(name); policy {
+	case DiscardOldest, DiscardNewest, DiscardRandom:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown discard policy %q", name)
+}
+
+// shelf is a shelf that holds prepared orders in the order they were placed
+type shelf struct {
+	name          string
+	capacity      int
+	decayModifier float64
+	orders        *list.List
+}
+
+func (s *shelf) full() bool {
+	return s.capacity > 0 && s.orders.Len() >= s.capacity
+}
+
+// shelfPlacement is where an order sits
+type shelfPlacement struct {
+	shelf   *shelf
+	element *list.Element
+}
+
+// shelves keep the prepared orders waiting for a courier. An order is placed
+// on the shelf for its temperature, or on the overflow shelf if that one is
+// full; when the overflow shelf is full as well, an order is discarded. Orders
+// decay while on the shelves, faster on the overflow shelf.
+// Not thread-safe; the order managers call it while holding their lock
+type shelves struct {
+	temperatureShelves map[string]*shelf
+	overflow           *shelf
+	all                []*shelf // in a fixed order, so that expiry is deterministic
+	policy             DiscardPolicy
+	random             *rand.Rand
+	placements         map[*DispatchedOrder]*shelfPlacement
+}
+
+func (s *shelves) put(sh *shelf, order *DispatchedOrder, now time.Time) {
+	if order.decayTime.IsZero() { // starts decaying once placed on a shelf
+		order.decayTime = now
+	}
+	s.placements[order] = &shelfPlacement{
+		shelf:   sh,
+		element: sh.orders.PushBack(order),
+	}
+	order.Shelf = sh.name
+}
+
+func (s *shelves) take(order *DispatchedOrder, now time.Time) *shelf {
+	placement, ok := s.placements[order]
+	if !ok {
+		return nil
+	}
+	delete(s.placements, order)
+	placement.shelf.orders.Remove(placement.element)
+	order.decayUntil(now, placement.shelf.decayModifier)
+	return placement.shelf
+}
+
+// place places a prepared order on a shelf, and returns the orders discarded
+// to make room for it (which may include the order itself)
+func (s *shelves) place(order *DispatchedOrder, now time.Time) []*DispatchedOrder {
+	if sh, ok := s.temperatureShelves[order.Order.Temp]; ok && !sh.full() {
+		s.put(sh, order, now)
+		return nil
+	}
+	if !s.overflow.full() {
+		s.put(s.overflow, order, now)
+		return nil
+	}
+	var discarded *DispatchedOrder
+	switch s.policy {
+	case DiscardNewest:
+		return []*DispatchedOrder{order}
+	case DiscardRandom:
+		element := s.overflow.orders.Front()
+		for i := s.random.Intn(s.overflow.orders.Len()); i > 0; i-- {
+			element = element.Next()
+		}
+		discarded = element.Value.(*DispatchedOrder)
+	default:
+		discarded = s.overflow.orders.Front().Value.(*DispatchedOrder)
+	}
+	s.take(discarded, now)
+	s.put(s.overflow, order, now)
+	return []*DispatchedOrder{discarded}
+}
+
+// remove removes an order from its shelf (if it is on one). If it frees up a
+// temperature shelf, the oldest order of that temperature on the overflow
+// shelf is moved onto it
+func (s *shelves) remove(order *DispatchedOrder, now time.Time) {
+	sh := s.take(order, now)
+	if sh == nil || sh == s.overflow {
+		return
+	}
+	for element := s.overflow.orders.Front(); element != nil; element = element.Next() {
+		if moved := element.Value.(*DispatchedOrder); moved.Order.Temp == sh.name {
+			s.take(moved, now)
+			s.put(sh, moved, now)
+			return
+		}
+	}
+}
+
+// expire removes and returns all the orders whose value has reached zero
+func (s *shelves) expire(now time.Time) []*DispatchedOrder {
+	var expired []*DispatchedOrder
+	for _, sh := range s.all {
+		for element := sh.orders.Front(); element != nil; element = element.Next() {
+			order := element.Value.(*DispatchedOrder)
+			order.decayUntil(now, sh.decayModifier)
+			if order.getValue() <= 0 {
+				expired = append(expired, order)
+			}
+		}
+	}
+	for _, order := range expired {
+		s.remove(order, now)
+	}
+	return expired
+}
+
+func newShelf(name string, capacity int, decayModifier float64) *shelf {
+	return &shelf{
+		name:          name,
+		capacity:      capacity,
+		decayModifier: decayModifier,
+		orders:        list.New(),
+	}
+}
+
+func getShelves(config ShelfConfig, random *rand.Rand) *shelves {
+	hot := newShelf(resource.TemperatureHot, config.HotCapacity, TemperatureDecayModifier)
+	cold := newShelf(resource.TemperatureCold, config.ColdCapacity, TemperatureDecayModifier)
+	frozen := newShelf(resource.TemperatureFrozen, config.FrozenCapacity, TemperatureDecayModifier)
+	overflow := newShelf(OverflowShelf, config.OverflowCapacity, OverflowDecayModifier)
+	return &shelves{
+		temperatureShelves: map[string]*shelf{
+			hot.name:    hot,
+			cold.name:   cold,
+			frozen.name: frozen,
+		},
+		overflow:   overflow,
+		all:        []*shelf{hot, cold, frozen, overflow},
+		policy:     config.DiscardPolicy,
+		random:     random,
+		placements: map[*DispatchedOrder]*shelfPlacement{},
+	}
+}
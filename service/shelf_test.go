@@ -0,0 +1,128 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"wonsoh.private/cloudkitchens/clock"
+	"wonsoh.private/cloudkitchens/resource"
+)
+
+type ShelfTestSuite struct {
+	suite.Suite
+	now time.Time
+}
+
+func (s *ShelfTestSuite) SetupTest() {
+	s.now = time.Now()
+}
+
+func (s *ShelfTestSuite) getOrder(id string, temp string) *DispatchedOrder {
+	return getDispatchedOrder(nil, clock.GetRealClock(), &resource.Order{
+		ID:   id,
+		Name: "Food " + id,
+		Temp: temp,
+	})
+}
+
+func (s *ShelfTestSuite) TestPlaceAndRemove() {
+	shelves := getShelves(ShelfConfig{HotCapacity: 1, OverflowCapacity: 2}, nil)
+	hot1, hot2, unknown := s.getOrder("1", resource.TemperatureHot), s.getOrder("2", resource.TemperatureHot), s.getOrder("3", "")
+	s.Empty(shelves.place(hot1, s.now))
+	s.Equal(resource.TemperatureHot, hot1.Shelf)
+	s.Empty(shelves.place(hot2, s.now))
+	s.Equal(OverflowShelf, hot2.Shelf) // hot shelf is full
+	s.Empty(shelves.place(unknown, s.now))
+	s.Equal(OverflowShelf, unknown.Shelf) // no shelf for its temperature
+
+	shelves.remove(hot1, s.now)
+	s.Equal(resource.TemperatureHot, hot2.Shelf) // moved up from the overflow shelf
+	s.Equal(1, shelves.overflow.orders.Len())
+	shelves.remove(hot1, s.now) // not on a shelf anymore
+	s.Equal(1, shelves.overflow.orders.Len())
+}
+
+func (s *ShelfTestSuite) TestUnlimited() {
+	shelves := getShelves(ShelfConfig{}, nil)
+	for i := 0; i < 100; i++ {
+		s.Empty(shelves.place(s.getOrder("1", resource.TemperatureCold), s.now))
+	}
+	s.Equal(100, shelves.temperatureShelves[resource.TemperatureCold].orders.Len())
+}
+
+func (s *ShelfTestSuite) TestDiscardPolicies() {
+	for _, tc := range []struct {
+		policy    DiscardPolicy
+		discarded string
+	}{
+		{policy: "", discarded: "1"},
+		{policy: DiscardOldest, discarded: "1"},
+		{policy: DiscardNewest, discarded: "3"},
+	} {
+		shelves := getShelves(ShelfConfig{FrozenCapacity: 1, OverflowCapacity: 1, DiscardPolicy: tc.policy}, nil)
+		s.Empty(shelves.place(s.getOrder("0", resource.TemperatureFrozen), s.now))
+		s.Empty(shelves.place(s.getOrder("1", resource.TemperatureFrozen), s.now))
+		s.Empty(shelves.place(s.getOrder("2", resource.TemperatureHot), s.now))
+		s.Equal(1, shelves.temperatureShelves[resource.TemperatureHot].orders.Len())
+		discarded := shelves.place(s.getOrder("3", resource.TemperatureFrozen), s.now)
+		s.Len(discarded, 1, tc.policy)
+		s.Equal(tc.discarded, discarded[0].Order.ID, tc.policy)
+		s.Equal(1, shelves.overflow.orders.Len(), tc.policy)
+	}
+
+	shelves := getShelves(
+		ShelfConfig{OverflowCapacity: 2, DiscardPolicy: DiscardRandom},
+		resource.GetFixedSeedRandomNumberGenerator(),
+	)
+	s.Empty(shelves.place(s.getOrder("1", ""), s.now))
+	s.Empty(shelves.place(s.getOrder("2", ""), s.now))
+	discarded := shelves.place(s.getOrder("3", ""), s.now)
+	s.Len(discarded, 1)
+	s.Contains([]string{"1", "2"}, discarded[0].Order.ID)
+	s.Equal(2, shelves.overflow.orders.Len())
+}
+
+func (s *ShelfTestSuite) TestDecay() {
+	shelves := getShelves(ShelfConfig{HotCapacity: 1}, nil)
+	getOrder := func(id string) *DispatchedOrder {
+		order := s.getOrder(id, resource.TemperatureHot)
+		order.Order.ShelfLife = 20
+		order.Order.DecayRate = 0.5
+		return order
+	}
+	onHot, onOverflow, keeper := getOrder("1"), getOrder("2"), s.getOrder("3", "")
+	s.Empty(shelves.place(onHot, s.now))
+	s.Empty(shelves.place(onOverflow, s.now))
+	s.Empty(shelves.place(keeper, s.now))
+
+	// after 4s, the order on the hot shelf lost 4 * (1 + 0.5) = 6 of 20 seconds;
+	// the one on the overflow shelf lost 4 * (1 + 0.5 * 2) = 8 of 20 seconds
+	s.Empty(shelves.expire(s.now.Add(4 * time.Second)))
+	s.InDelta(0.7, onHot.getValue(), 1e-9)
+	s.InDelta(0.6, onOverflow.getValue(), 1e-9)
+
+	// picking up the first one moves the second one up to the hot shelf, where
+	// it decays slower: it lasts for another 12 / 1.5 = 8 seconds
+	shelves.remove(onHot, s.now.Add(4*time.Second))
+	s.Equal(resource.TemperatureHot, onOverflow.Shelf)
+	s.Empty(shelves.expire(s.now.Add(11 * time.Second)))
+	s.Equal([]*DispatchedOrder{onOverflow}, shelves.expire(s.now.Add(12*time.Second)))
+	s.EqualValues(0, onOverflow.getValue())
+	s.EqualValues(1, keeper.getValue()) // no shelf life
+	s.Empty(shelves.placements[onOverflow])
+}
+
+func (s *ShelfTestSuite) TestParseDiscardPolicy() {
+	for _, name := range []string{"oldest", "newest", "random"} {
+		policy, err := ParseDiscardPolicy(name)
+		s.NoError(err)
+		s.EqualValues(name, policy)
+	}
+	_, err := ParseDiscardPolicy("youngest")
+	s.Error(err)
+}
+
+func TestShelfTestSuite(t *testing.T) {
+	suite.Run(t, new(ShelfTestSuite))
+}
@@ -0,0 +1,193 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// WaitTimeBucketBounds are the upper bounds (exclusive, in ms) of the buckets
+// of the wait time histograms; the last bucket counts all the longer waits
+var WaitTimeBucketBounds = []int{1000, 2000, 5000, 10000, 30000, 60000}
+
+// WaitTimeSummary summarizes the distribution of wait times, in ms
+type WaitTimeSummary struct {
+	Count int
+	Min   int
+	Max   int
+	P50   int
+	P90   int
+	P95   int
+	P99   int
+	// Approximate is whether the percentiles are within 1% of the exact ones
+	// (see WaitTimeSketch) rather than exact
+	Approximate bool
+	// Histogram is the number of wait times in each bucket of
+	// WaitTimeBucketBounds, followed by the number of longer ones
+	Histogram []int
+}
+
+// waitTimeSketchAccuracy is the relative accuracy of the percentiles of a
+// WaitTimeSketch
+const waitTimeSketchAccuracy = 0.01
+
+// waitTimeSketchGamma is the ratio of the upper to the lower bound of each
+// bucket of a WaitTimeSketch, so that any value of a bucket is within
+// waitTimeSketchAccuracy of its middle
+var waitTimeSketchGamma = (1 + waitTimeSketchAccuracy) / (1 - waitTimeSketchAccuracy)
+
+// WaitTimeSketch keeps the distribution of wait times in a bounded amount of
+// memory, however many there are: the count, the minimum, the maximum, and the
+// histogram are exact, while the percentiles are within 1% of the exact ones.
+// The waits are counted in buckets whose bounds grow by a constant ratio
+// (waitTimeSketchGamma), so that a wait of a day still takes fewer than 1000
+// of them. The zero value is an empty sketch; an exact sketch (see
+// getExactWaitTimeSketch) keeps every wait as well, for exact percentiles
+type WaitTimeSketch struct {
+	count     int
+	min       int
+	max       int
+	zeros     int   // the number of waits of 0 ms (or less)
+	buckets   []int // the number of longer waits, by getWaitTimeBucket
+	histogram []int // the number of waits in each of WaitTimeBucketBounds
+	exact     bool  // whether every wait is kept
+	waitTimes []int // every wait, if exact
+}
+
+// getWaitTimeBucket <private> gets the bucket of a wait time of at least 1 ms,
+// which holds the waits in (gamma^(bucket-1), gamma^bucket]
+func getWaitTimeBucket(waitTime int) int {
+	return int(math.Ceil(math.Log(float64(waitTime)) / math.Log(waitTimeSketchGamma)))
+}
+
+// Add adds a wait time, in ms
+func (w *WaitTimeSketch) Add(waitTime int) {
+	if w.count == 0 || waitTime < w.min {
+		w.min = waitTime
+	}
+	if w.count == 0 || waitTime > w.max {
+		w.max = waitTime
+	}
+	w.count++
+	if w.histogram == nil {
+		w.histogram = make([]int, len(WaitTimeBucketBounds)+1)
+	}
+	w.histogram[sort.SearchInts(WaitTimeBucketBounds, waitTime+1)]++
+	if w.exact {
+		w.waitTimes = append(w.waitTimes, waitTime)
+	}
+	if waitTime <= 0 {
+		w.zeros++
+		return
+	}
+	bucket := getWaitTimeBucket(waitTime)
+	for len(w.buckets) <= bucket {
+		w.buckets = append(w.buckets, 0)
+	}
+	w.buckets[bucket]++
+}
+
+// percentile <private> gets the nearest-rank percentile of the wait times, as
+// the middle of its bucket (within the minimum and the maximum)
+func (w *WaitTimeSketch) percentile(p float64) int {
+	rank := int(math.Ceil(p / 100 * float64(w.count)))
+	if rank <= w.zeros {
+		return w.min
+	}
+	seen := w.zeros
+	for bucket, count := range w.buckets {
+		if seen += count; seen >= rank {
+			middle := int(math.Round(2 * math.Pow(waitTimeSketchGamma, float64(bucket)) / (waitTimeSketchGamma + 1)))
+			if middle < w.min {
+				return w.min
+			}
+			if middle > w.max {
+				return w.max
+			}
+			return middle
+		}
+	}
+	return w.max
+}
+
+// exactPercentile <private> gets the nearest-rank percentile of sorted wait
+// times
+func exactPercentile(sorted []int, p float64) int {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// GetSummary summarizes the wait times added so far
+func (w *WaitTimeSketch) GetSummary() WaitTimeSummary {
+	summary := WaitTimeSummary{
+		Count:       w.count,
+		Approximate: !w.exact,
+		Histogram:   make([]int, len(WaitTimeBucketBounds)+1),
+	}
+	if w.count == 0 {
+		return summary
+	}
+	copy(summary.Histogram, w.histogram)
+	summary.Min = w.min
+	summary.Max = w.max
+	percentile := w.percentile
+	if w.exact {
+		sorted := append([]int(nil), w.waitTimes...)
+		sort.Ints(sorted)
+		percentile = func(p float64) int {
+			return exactPercentile(sorted, p)
+		}
+	}
+	summary.P50 = percentile(50)
+	summary.P90 = percentile(90)
+	summary.P95 = percentile(95)
+	summary.P99 = percentile(99)
+	return summary
+}
+
+// getExactWaitTimeSketch <private> gets an empty sketch that keeps every wait,
+// so that its percentiles are exact
+func getExactWaitTimeSketch() WaitTimeSketch {
+	return WaitTimeSketch{exact: true}
+}
+
+// GetWaitTimeSummary summarizes the given wait times, with exact percentiles
+func GetWaitTimeSummary(waitTimes []int) WaitTimeSummary {
+	sketch := getExactWaitTimeSketch()
+	for _, waitTime := range waitTimes {
+		sketch.Add(waitTime)
+	}
+	return sketch.GetSummary()
+}
+
+// String formats the summary for the statistics report
+func (w WaitTimeSummary) String() string {
+	builder := &strings.Builder{}
+	fmt.Fprintf(
+		builder,
+		"min %d / p50 %d / p90 %d / p95 %d / p99 %d / max %d ms",
+		w.Min,
+		w.P50,
+		w.P90,
+		w.P95,
+		w.P99,
+		w.Max,
+	)
+	if w.Approximate {
+		fmt.Fprintf(builder, " (percentiles within %v%%)", waitTimeSketchAccuracy*100)
+	}
+	lower := 0
+	for i, count := range w.Histogram {
+		if i < len(WaitTimeBucketBounds) {
+			fmt.Fprintf(builder, "\n\t\t\t[%d, %d) ms:\t%d", lower, WaitTimeBucketBounds[i], count)
+			lower = WaitTimeBucketBounds[i]
+		} else {
+			fmt.Fprintf(builder, "\n\t\t\t[%d, ...) ms:\t%d", lower, count)
+		}
+	}
+	return builder.String()
+}
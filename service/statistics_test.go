@@ -0,0 +1,93 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type StatisticsTestSuite struct {
+	suite.Suite
+}
+
+func (s *StatisticsTestSuite) TestEmptySummary() {
+	summary := GetWaitTimeSummary(nil)
+	s.Equal(0, summary.Count)
+	s.Equal(0, summary.P95)
+	s.Equal(make([]int, len(WaitTimeBucketBounds)+1), summary.Histogram)
+	s.NotPanics(func() {
+		_ = summary.String()
+	})
+}
+
+func (s *StatisticsTestSuite) TestSummary() {
+	waitTimes := make([]int, 0, 100)
+	for i := 100; i > 0; i-- { // 100 ms to 10 s, out of order
+		waitTimes = append(waitTimes, i*100)
+	}
+	summary := GetWaitTimeSummary(waitTimes)
+	s.Equal(100, summary.Count)
+	s.Equal(100, summary.Min)
+	s.Equal(10000, summary.Max)
+	s.Equal(5000, summary.P50)
+	s.Equal(9000, summary.P90)
+	s.Equal(9500, summary.P95)
+	s.Equal(9900, summary.P99)
+	s.False(summary.Approximate)
+	s.Equal([]int{9, 10, 30, 50, 1, 0, 0}, summary.Histogram)
+	s.Contains(summary.String(), "max 10000 ms")
+	s.NotContains(summary.String(), "within")
+}
+
+func (s *StatisticsTestSuite) TestSmallSample() {
+	summary := GetWaitTimeSummary([]int{0, 2000, 0, 2000})
+	s.Equal(0, summary.P50)
+	s.Equal(2000, summary.P90)
+	s.Equal(2000, summary.P99)
+	s.Equal([]int{2, 0, 2, 0, 0, 0, 0}, summary.Histogram)
+
+	summary = GetWaitTimeSummary([]int{1234})
+	s.Equal(1234, summary.P50) // within the minimum and the maximum
+}
+
+func (s *StatisticsTestSuite) TestSketch() {
+	// a million waits of up to an hour take a bounded number of buckets
+	sketch := &WaitTimeSketch{}
+	for i := 1; i <= 1000000; i++ {
+		sketch.Add(i * 36 / 10)
+	}
+	s.Less(len(sketch.buckets), 1000)
+	summary := sketch.GetSummary()
+	s.Equal(1000000, summary.Count)
+	s.Equal(3, summary.Min)
+	s.Equal(3600000, summary.Max)
+	s.InEpsilon(1800000, summary.P50, 0.01)
+	s.InEpsilon(3564000, summary.P99, 0.01)
+	s.True(summary.Approximate)
+	s.Contains(summary.String(), "(percentiles within 1%)")
+	total := 0
+	for _, count := range summary.Histogram {
+		total += count
+	}
+	s.Equal(1000000, total)
+	s.Equal(277, summary.Histogram[0]) // the waits of 3 to 997 ms
+}
+
+func (s *StatisticsTestSuite) TestExactSketch() {
+	// 1001 is in the same bucket as 1000 and 1010, whose middle it is not
+	approximate, exact := &WaitTimeSketch{}, getExactWaitTimeSketch()
+	for _, waitTime := range []int{1000, 1001, 1010, 5000} {
+		approximate.Add(waitTime)
+		exact.Add(waitTime)
+	}
+	s.NotEqual(1001, approximate.GetSummary().P50)
+	summary := exact.GetSummary()
+	s.Equal(1001, summary.P50)
+	s.Equal(5000, summary.P99)
+	s.False(summary.Approximate)
+	s.Equal(approximate.GetSummary().Histogram, summary.Histogram)
+}
+
+func TestStatisticsTestSuite(t *testing.T) {
+	suite.Run(t, new(StatisticsTestSuite))
+}
@@ -0,0 +1,288 @@
+package service
+
+import (
+	"container/list"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	"wonsoh.private/cloudkitchens/resource"
+)
+
+const (
+	// MatchedStrategyName is the name of the strategy that lets a courier pick
+	// up only the order it was dispatched for
+	MatchedStrategyName = "matched"
+	// FIFOStrategyName is the name of the strategy that lets a courier pick up
+	// the earliest prepared order
+	FIFOStrategyName = "fifo"
+	// NearestStrategyName is the name of the strategy that dispatches a
+	// courier once an order is ready, the closest free courier of a limited
+	// fleet, unless a courier waiting at the kitchen picks the order up
+	NearestStrategyName = "nearest"
+)
+
+// Strategy decides which courier picks up which prepared order. The order
+// managers call it while holding their lock, so it does not need to be
+// thread-safe
+type Strategy interface {
+	// Init clears all the waiting orders and couriers
+	Init()
+	// OrderReady returns the waiting courier that picks up the prepared order,
+	// or nil if the order has to wait for a courier
+	OrderReady(order *DispatchedOrder) *DispatchedCourier
+	// CourierArrived returns the waiting order that the arrived courier picks
+	// up; if there is none, it returns whether the courier has to wait for an
+	// order (or leave empty-handed)
+	CourierArrived(courier *DispatchedCourier) (order *DispatchedOrder, wait bool)
+	// RemoveOrder removes a waiting order that is never going to be picked up
+	// (e.g. discarded from the shelves)
+	RemoveOrder(order *DispatchedOrder)
+	// RemoveCourier removes a courier that is never going to pick up an order
+	// (e.g. recalled since its order has been cancelled), whether it is waiting
+	// or yet to arrive; returns whether it was waiting
+	RemoveCourier(courier *DispatchedCourier) bool
+}
+
+// CourierChooser is implemented by the strategies that also choose which free
+// courier of a limited fleet is dispatched on a trip to the kitchen; the
+// courier that has been free the longest is dispatched otherwise
+type CourierChooser interface {
+	// ChooseCourier returns the index of the free courier to dispatch to the
+	// kitchen, given the free couriers in the order they became free (with
+	// their locations if there is a geographic model)
+	ChooseCourier(kitchen resource.Location, free []*resource.Courier) int
+}
+
+// StackingStrategy is implemented by the strategies that let a courier pick up
+// more prepared orders in the same visit to the kitchen (up to the stack size
+// of the fleet); a courier picks up a single order otherwise
+type StackingStrategy interface {
+	// NextOrder removes and returns the waiting order that a courier who has
+	// just picked up an order picks up as well, or nil if there is none
+	NextOrder() *DispatchedOrder
+}
+
+// ReadyDispatchStrategy is implemented by the strategies that dispatch the
+// courier of an order once the order is ready rather than once it is
+// dispatched, so that the courier is chosen among the couriers free at that
+// moment; no courier is dispatched for an order that a courier waiting at the
+// kitchen picks up
+type ReadyDispatchStrategy interface {
+	// DispatchOnReady returns whether the couriers are dispatched once their
+	// orders are ready
+	DispatchOnReady() bool
+}
+
+// StrategyFactory constructs a new strategy, with nothing waiting
+type StrategyFactory func() Strategy
+
+var (
+	strategyMutex     = &sync.RWMutex{}
+	strategyFactories = map[string]StrategyFactory{
+		MatchedStrategyName: func() Strategy { return getMatchedStrategy() },
+		FIFOStrategyName:    func() Strategy { return getFIFOStrategy() },
+		NearestStrategyName: func() Strategy { return getNearestStrategy() },
+		BatchStrategyName:   func() Strategy { return getBatchStrategy(DefaultBatchWindow) },
+	}
+)
+
+// RegisterStrategy makes a strategy available by name, e.g. for the order
+// managers of another package; a name can only be registered once
+func RegisterStrategy(name string, factory StrategyFactory) error {
+	strategyMutex.Lock()
+	defer strategyMutex.Unlock()
+	if name == "" || factory == nil {
+		return fmt.Errorf("strategy must have a name and a factory")
+	}
+	if _, ok := strategyFactories[name]; ok {
+		return fmt.Errorf("strategy %q is already registered", name)
+	}
+	strategyFactories[name] = factory
+	return nil
+}
+
+// GetStrategy constructs a new instance of the strategy registered by name
+func GetStrategy(name string) (Strategy, error) {
+	strategyMutex.RLock()
+	defer strategyMutex.RUnlock()
+	factory, ok := strategyFactories[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown strategy %q", name)
+	}
+	return factory(), nil
+}
+
+// GetStrategyNames gets the names of all the registered strategies, in
+// alphabetical order
+func GetStrategyNames() []string {
+	strategyMutex.RLock()
+	defer strategyMutex.RUnlock()
+	names := make([]string, 0, len(strategyFactories))
+	for name := range strategyFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// matchedStrategy lets a courier pick up only the order it was dispatched for;
+// the orders and couriers are matched by the dispatched order rather than by
+// its ID, which a later order may reuse once the order is finished
+type matchedStrategy struct {
+	finishedOrderMap *sync.Map // by themselves
+	courierMap       *sync.Map // by the order they were dispatched for
+	removedOrderMap  *sync.Map // couriers of removed orders leave on arrival
+}
+
+// fifoStrategy lets a courier pick up the earliest prepared order, and a
+// prepared order go to the earliest arrived courier
+type fifoStrategy struct {
+	finishedOrderQueue *list.List
+	courierQueue       *list.List
+	orderElements      map[*DispatchedOrder]*list.Element
+	courierElements    map[*DispatchedCourier]*list.Element
+}
+
+// nearestStrategy assigns a prepared order to the nearest courier at that
+// moment: a courier waiting at the kitchen (the earliest arrived first), or
+// else the closest free courier of a limited fleet, which is dispatched then.
+// At the kitchen, the orders and couriers are matched in FIFO order
+type nearestStrategy struct {
+	*fifoStrategy
+}
+
+func (m *matchedStrategy) Init() {
+	m.finishedOrderMap = &sync.Map{}
+	m.courierMap = &sync.Map{}
+	m.removedOrderMap = &sync.Map{}
+}
+
+func (m *matchedStrategy) OrderReady(order *DispatchedOrder) *DispatchedCourier {
+	courier, ok := m.courierMap.LoadAndDelete(order)
+	if !ok { // since courier is not found, wait in line
+		m.finishedOrderMap.Store(order, order)
+		return nil
+	}
+	return courier.(*DispatchedCourier)
+}
+
+func (m *matchedStrategy) CourierArrived(courier *DispatchedCourier) (*DispatchedOrder, bool) {
+	order, ok := m.finishedOrderMap.LoadAndDelete(courier.order)
+	if !ok {
+		if _, removed := m.removedOrderMap.LoadAndDelete(courier.order); removed {
+			return nil, false // the order is gone; leave
+		}
+		m.courierMap.Store(courier.order, courier) // since order is not ready, wait for it
+		return nil, true
+	}
+	return order.(*DispatchedOrder), false
+}
+
+func (m *matchedStrategy) RemoveOrder(order *DispatchedOrder) {
+	if _, ok := m.finishedOrderMap.LoadAndDelete(order); ok {
+		m.removedOrderMap.Store(order, order)
+	}
+}
+
+func (m *matchedStrategy) RemoveCourier(courier *DispatchedCourier) bool {
+	m.removedOrderMap.Delete(courier.order) // no longer arrives to find it gone
+	_, ok := m.courierMap.LoadAndDelete(courier.order)
+	return ok
+}
+
+func (f *fifoStrategy) Init() {
+	f.finishedOrderQueue.Init()
+	f.courierQueue.Init()
+	f.orderElements = map[*DispatchedOrder]*list.Element{}
+	f.courierElements = map[*DispatchedCourier]*list.Element{}
+}
+
+func (f *fifoStrategy) OrderReady(order *DispatchedOrder) *DispatchedCourier {
+	if f.courierQueue.Len() == 0 { // since courier is not found, wait in line
+		f.orderElements[order] = f.finishedOrderQueue.PushBack(order)
+		return nil
+	}
+	// the earliest arrived courier is evicted from the queue
+	courier := f.courierQueue.Remove(f.courierQueue.Front()).(*DispatchedCourier)
+	delete(f.courierElements, courier)
+	return courier
+}
+
+func (f *fifoStrategy) CourierArrived(courier *DispatchedCourier) (*DispatchedOrder, bool) {
+	if f.finishedOrderQueue.Len() == 0 { // since order is not ready, wait in line
+		f.courierElements[courier] = f.courierQueue.PushBack(courier)
+		return nil, true
+	}
+	// the earliest prepared order is evicted from the queue
+	order := f.finishedOrderQueue.Remove(f.finishedOrderQueue.Front()).(*DispatchedOrder)
+	delete(f.orderElements, order)
+	return order, false
+}
+
+func (f *fifoStrategy) NextOrder() *DispatchedOrder {
+	if f.finishedOrderQueue.Len() == 0 {
+		return nil
+	}
+	// the earliest prepared order is evicted from the queue
+	order := f.finishedOrderQueue.Remove(f.finishedOrderQueue.Front()).(*DispatchedOrder)
+	delete(f.orderElements, order)
+	return order
+}
+
+func (f *fifoStrategy) RemoveOrder(order *DispatchedOrder) {
+	if element, ok := f.orderElements[order]; ok {
+		delete(f.orderElements, order)
+		f.finishedOrderQueue.Remove(element)
+	}
+}
+
+func (f *fifoStrategy) RemoveCourier(courier *DispatchedCourier) bool {
+	element, ok := f.courierElements[courier]
+	if ok {
+		delete(f.courierElements, courier)
+		f.courierQueue.Remove(element)
+	}
+	return ok
+}
+
+func (n *nearestStrategy) DispatchOnReady() bool {
+	return true
+}
+
+func (n *nearestStrategy) ChooseCourier(kitchen resource.Location, free []*resource.Courier) int {
+	nearest, shortest := 0, math.Inf(1)
+	for i, courier := range free {
+		if courier.Location == nil { // no geographic model; all equally close
+			return 0
+		}
+		if distance := courier.Location.Distance(kitchen); distance < shortest {
+			nearest, shortest = i, distance
+		}
+	}
+	return nearest
+}
+
+func getMatchedStrategy() *matchedStrategy {
+	return &matchedStrategy{
+		finishedOrderMap: &sync.Map{},
+		courierMap:       &sync.Map{},
+		removedOrderMap:  &sync.Map{},
+	}
+}
+
+func getFIFOStrategy() *fifoStrategy {
+	return &fifoStrategy{
+		finishedOrderQueue: list.New(),
+		courierQueue:       list.New(),
+		orderElements:      map[*DispatchedOrder]*list.Element{},
+		courierElements:    map[*DispatchedCourier]*list.Element{},
+	}
+}
+
+func getNearestStrategy() *nearestStrategy {
+	return &nearestStrategy{
+		fifoStrategy: getFIFOStrategy(),
+	}
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"wonsoh.private/cloudkitchens/clock"
+	"wonsoh.private/cloudkitchens/resource"
+)
+
+type StrategyTestSuite struct {
+	suite.Suite
+}
+
+func (s *StrategyTestSuite) getOrderAndCourier(id string) (*DispatchedOrder, *DispatchedCourier) {
+	clk := clock.GetRealClock()
+	order := getDispatchedOrder(nil, clk, &resource.Order{ID: id, Name: "Food " + id})
+	courier := getDispatchedCourier(nil, clk, resource.NewCourier(id, 3))
+	order.courier, courier.order = courier, order
+	return order, courier
+}
+
+// assertArrival asserts the order the arrived courier picks up, and whether
+// the courier waits when there is none
+func (s *StrategyTestSuite) assertArrival(
+	strategy Strategy,
+	courier *DispatchedCourier,
+	expectedOrder *DispatchedOrder,
+	expectedWait bool,
+) {
+	order, wait := strategy.CourierArrived(courier)
+	s.Equal(expectedOrder, order)
+	s.Equal(expectedWait, wait)
+}
+
+func (s *StrategyTestSuite) TestMatchedStrategy() {
+	strategy := getMatchedStrategy()
+	order1, courier1 := s.getOrderAndCourier("1")
+	order2, courier2 := s.getOrderAndCourier("2")
+
+	s.Nil(strategy.OrderReady(order1))
+	s.assertArrival(strategy, courier2, nil, true) // waits for its own order only
+	s.assertArrival(strategy, courier1, order1, false)
+	s.Equal(courier2, strategy.OrderReady(order2))
+
+	s.Nil(strategy.OrderReady(order1))
+	strategy.RemoveOrder(order1)
+	s.assertArrival(strategy, courier1, nil, false) // leaves since the order is gone
+
+	s.assertArrival(strategy, courier2, nil, true)
+	s.True(strategy.RemoveCourier(courier2)) // was waiting
+	s.False(strategy.RemoveCourier(courier2))
+	s.Nil(strategy.OrderReady(order2))
+
+	s.Nil(strategy.OrderReady(order1))
+	strategy.Init()
+	s.assertArrival(strategy, courier1, nil, true) // waiting order has been cleared
+}
+
+func (s *StrategyTestSuite) TestFIFOStrategy() {
+	strategy := getFIFOStrategy()
+	order1, courier1 := s.getOrderAndCourier("1")
+	order2, courier2 := s.getOrderAndCourier("2")
+
+	s.Nil(strategy.OrderReady(order2))
+	s.Nil(strategy.OrderReady(order1))
+	s.assertArrival(strategy, courier1, order2, false) // earliest prepared order
+	s.assertArrival(strategy, courier2, order1, false)
+
+	s.assertArrival(strategy, courier2, nil, true)
+	s.assertArrival(strategy, courier1, nil, true)
+	s.Equal(courier2, strategy.OrderReady(order1)) // earliest arrived courier
+	s.True(strategy.RemoveCourier(courier1))
+	s.False(strategy.RemoveCourier(courier1))
+	s.Nil(strategy.OrderReady(order1)) // no courier is waiting anymore
+
+	strategy.Init()
+	s.Nil(strategy.OrderReady(order2)) // waiting courier has been cleared
+}
+
+func (s *StrategyTestSuite) TestNextOrder() {
+	strategy := getFIFOStrategy()
+	order1, courier := s.getOrderAndCourier("1")
+	order2, _ := s.getOrderAndCourier("2")
+	order3, _ := s.getOrderAndCourier("3")
+	s.Nil(strategy.NextOrder())
+	s.Nil(strategy.OrderReady(order1))
+	s.Nil(strategy.OrderReady(order2))
+	s.Nil(strategy.OrderReady(order3))
+	s.assertArrival(strategy, courier, order1, false)
+	s.Equal(order2, strategy.NextOrder()) // stacked with order 1
+	strategy.RemoveOrder(order3)
+	s.Nil(strategy.NextOrder())
+
+	var _ StackingStrategy = getNearestStrategy() // stacks as FIFO does
+}
+
+func (s *StrategyTestSuite) TestNearestStrategy() {
+	strategy := getNearestStrategy()
+	order1, courier1 := s.getOrderAndCourier("1")
+	order2, courier2 := s.getOrderAndCourier("2")
+	s.Nil(strategy.OrderReady(order2))
+	s.assertArrival(strategy, courier1, order2, false) // FIFO at the kitchen
+	s.assertArrival(strategy, courier2, nil, true)
+	s.Equal(courier2, strategy.OrderReady(order1))
+	s.True(strategy.DispatchOnReady())
+	_, ok := Strategy(getFIFOStrategy()).(ReadyDispatchStrategy)
+	s.False(ok) // dispatches the couriers along with the orders
+
+	kitchen := resource.Location{X: 10, Y: 10}
+	s.Equal(1, strategy.ChooseCourier(kitchen, []*resource.Courier{
+		{Location: &resource.Location{X: 60, Y: 10}},
+		{Location: &resource.Location{X: -10, Y: 0}}, // the closest
+		{Location: &resource.Location{X: 10, Y: 50}},
+		{Location: &resource.Location{X: 30, Y: 20}}, // as close, but free for less long
+	}))
+	s.Equal(0, strategy.ChooseCourier(kitchen, []*resource.Courier{{}, {}})) // no locations
+}
+
+func (s *StrategyTestSuite) TestRegistry() {
+	for _, name := range []string{MatchedStrategyName, FIFOStrategyName, NearestStrategyName, BatchStrategyName} {
+		strategy, err := GetStrategy(name)
+		s.NoError(err)
+		other, _ := GetStrategy(name)
+		s.NotSame(strategy, other) // a new instance every time
+	}
+	_, err := GetStrategy("unknown")
+	s.Error(err)
+
+	s.NoError(RegisterStrategy("test", func() Strategy { return getFIFOStrategy() }))
+	s.Error(RegisterStrategy("test", func() Strategy { return getFIFOStrategy() }))
+	s.Error(RegisterStrategy(FIFOStrategyName, func() Strategy { return getFIFOStrategy() }))
+	s.Error(RegisterStrategy("", func() Strategy { return getFIFOStrategy() }))
+	s.Error(RegisterStrategy("nil", nil))
+	s.Equal([]string{BatchStrategyName, FIFOStrategyName, MatchedStrategyName, NearestStrategyName, "test"}, GetStrategyNames())
+
+	strategy, err := GetStrategy("test")
+	s.NoError(err)
+	order, courier := s.getOrderAndCourier("1")
+	s.Nil(strategy.OrderReady(order))
+	s.assertArrival(strategy, courier, order, false)
+}
+
+func TestStrategyTestSuite(t *testing.T) {
+	suite.Run(t, new(StrategyTestSuite))
+}
@@ -0,0 +1,245 @@
+package service
+
+import (
+	"context"
+	"sync"
+
+	"wonsoh.private/cloudkitchens/clock"
+)
+
+// Listener is called with the events of an order manager
+type Listener func(event Event)
+
+// ChannelSubscription receives the events of an order manager through a
+// buffered channel. Events are dropped (and counted) while the channel is
+// full, so that a slow subscriber never holds up the order manager
+type ChannelSubscription struct {
+	// Events receives the events in order; it is closed on Unsubscribe
+	Events <-chan Event
+
+	events      chan Event
+	mutex       *sync.Mutex
+	closed      bool
+	dropped     int
+	unsubscribe func()
+}
+
+// Dropped gets the number of events dropped since the channel was full
+func (c *ChannelSubscription) Dropped() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.dropped
+}
+
+// Unsubscribe stops the events and closes the channel
+func (c *ChannelSubscription) Unsubscribe() {
+	c.unsubscribe()
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if !c.closed {
+		c.closed = true
+		close(c.events)
+	}
+}
+
+// send <private> sends the event unless the channel is full or closed
+func (c *ChannelSubscription) send(event Event) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.closed {
+		return
+	}
+	select {
+	case c.events <- event:
+	default:
+		c.dropped++
+	}
+}
+
+// subscriber is a listener with the identifier it was registered with
+type subscriber struct {
+	id       int
+	listener Listener
+}
+
+// eventHub numbers the events of an order manager and delivers them. The order
+// manager emits the events while holding its lock, and delivers them to the
+// listeners once it has released the lock, so that the listeners never hold up
+// the matching. The channels are sent the events by a goroutine of the hub,
+// started with the clock of the order manager, so that a subscriber that does
+// not keep up never holds up the goroutine emitting them either
+type eventHub struct {
+	clock clock.Clock
+
+	queueMutex   *sync.Mutex
+	queue        []Event // to deliver to the listeners
+	channelQueue []Event // to send to the channels
+	sequence     int64
+	sending      bool       // whether the goroutine sending to the channels runs
+	sent         *sync.Cond // signalled once the channel queue has been sent
+
+	deliveryMutex *sync.Mutex // one goroutine delivers at a time, in order
+
+	subscriberMutex *sync.RWMutex
+	subscribers     []subscriber
+	channels        []subscriber
+	nextID          int
+}
+
+// emit <private> numbers the event and queues it up for delivery, starting the
+// goroutine that sends the events to the channels unless it runs already (or
+// there are no channels). Must be called with the lock of the order manager
+// held, so that the events are numbered in the order they happened
+func (h *eventHub) emit(event Event) {
+	h.subscriberMutex.RLock()
+	channels := len(h.channels) > 0
+	h.subscriberMutex.RUnlock()
+	h.queueMutex.Lock()
+	defer h.queueMutex.Unlock()
+	h.sequence++
+	event.Sequence = h.sequence
+	h.queue = append(h.queue, event)
+	if !channels {
+		return
+	}
+	h.channelQueue = append(h.channelQueue, event)
+	if !h.sending {
+		h.sending = true
+		h.clock.Go(h.send)
+	}
+}
+
+// deliver <private> delivers all the queued events to the listeners, in order.
+// Must be called without the lock of the order manager held
+func (h *eventHub) deliver() {
+	h.deliveryMutex.Lock()
+	defer h.deliveryMutex.Unlock()
+	for {
+		h.queueMutex.Lock()
+		events := h.queue
+		h.queue = nil
+		h.queueMutex.Unlock()
+		if len(events) == 0 {
+			return
+		}
+		h.subscriberMutex.RLock()
+		subscribers := h.subscribers
+		h.subscriberMutex.RUnlock()
+		for _, event := range events {
+			for _, s := range subscribers {
+				s.listener(event)
+			}
+		}
+	}
+}
+
+// send <private> sends the queued events to the channels, in order, until the
+// queue is empty
+func (h *eventHub) send() {
+	for {
+		h.queueMutex.Lock()
+		events := h.channelQueue
+		h.channelQueue = nil
+		if len(events) == 0 {
+			h.sending = false
+			h.sent.Broadcast()
+			h.queueMutex.Unlock()
+			return
+		}
+		h.queueMutex.Unlock()
+		h.subscriberMutex.RLock()
+		channels := h.channels
+		h.subscriberMutex.RUnlock()
+		for _, event := range events {
+			for _, c := range channels {
+				c.listener(event)
+			}
+		}
+	}
+}
+
+// flush <private> delivers the events emitted so far, and waits until they
+// have been sent to the channels as well, or until the context is done
+func (h *eventHub) flush(ctx context.Context) {
+	delivered := make(chan struct{})
+	go func() {
+		h.deliver()
+		close(delivered)
+	}()
+	select {
+	case <-delivered:
+	case <-ctx.Done(): // a listener is still busy
+		return
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done(): // wakes up the wait below
+			h.queueMutex.Lock()
+			h.sent.Broadcast()
+			h.queueMutex.Unlock()
+		case <-done:
+		}
+	}()
+	h.queueMutex.Lock()
+	defer h.queueMutex.Unlock()
+	for h.sending && ctx.Err() == nil {
+		h.sent.Wait()
+	}
+}
+
+// subscribe <private> registers a listener; returns the function that
+// unregisters it
+func (h *eventHub) subscribe(listener Listener) func() {
+	return h.register(&h.subscribers, listener)
+}
+
+// subscribeChannel <private> registers a buffered channel of the given size
+func (h *eventHub) subscribeChannel(size int) *ChannelSubscription {
+	events := make(chan Event, size)
+	subscription := &ChannelSubscription{
+		Events: events,
+		events: events,
+		mutex:  &sync.Mutex{},
+	}
+	subscription.unsubscribe = h.register(&h.channels, subscription.send)
+	return subscription
+}
+
+// register <private> adds the listener to the list of subscribers; returns
+// the function that removes it
+func (h *eventHub) register(list *[]subscriber, listener Listener) func() {
+	h.subscriberMutex.Lock()
+	defer h.subscriberMutex.Unlock()
+	h.nextID++
+	id := h.nextID
+	// copied on write, so that a delivery in progress keeps its own list
+	*list = append(append([]subscriber(nil), *list...), subscriber{
+		id:       id,
+		listener: listener,
+	})
+	return func() {
+		h.subscriberMutex.Lock()
+		defer h.subscriberMutex.Unlock()
+		subscribers := make([]subscriber, 0, len(*list))
+		for _, s := range *list {
+			if s.id != id {
+				subscribers = append(subscribers, s)
+			}
+		}
+		*list = subscribers
+	}
+}
+
+func getEventHub(clk clock.Clock) *eventHub {
+	queueMutex := &sync.Mutex{}
+	return &eventHub{
+		clock:           clk,
+		queueMutex:      queueMutex,
+		sent:            sync.NewCond(queueMutex),
+		deliveryMutex:   &sync.Mutex{},
+		subscriberMutex: &sync.RWMutex{},
+	}
+}
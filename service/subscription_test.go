@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/suite"
+	"wonsoh.private/cloudkitchens/clock"
+	"wonsoh.private/cloudkitchens/resource"
+)
+
+type SubscriptionTestSuite struct {
+	suite.Suite
+}
+
+func (s *SubscriptionTestSuite) TestEventHub() {
+	hub := getEventHub(clock.GetRealClock())
+	var received []Event
+	unsubscribe := hub.subscribe(func(event Event) {
+		received = append(received, event)
+	})
+	hub.emit(Event{Type: EventOrderDispatched})
+	hub.emit(Event{Type: EventOrderReceived})
+	s.Empty(received) // until delivered
+	hub.deliver()
+	s.Len(received, 2)
+	s.EqualValues(1, received[0].Sequence)
+	s.EqualValues(2, received[1].Sequence)
+	s.Equal(EventOrderReceived, received[1].Type)
+
+	unsubscribe()
+	hub.emit(Event{Type: EventOrderPrepared})
+	hub.flush(context.Background())
+	s.Len(received, 2)
+}
+
+func (s *SubscriptionTestSuite) TestChannelSubscription() {
+	hub := getEventHub(clock.GetRealClock())
+	subscription := hub.subscribeChannel(2)
+	for i := 0; i < 5; i++ {
+		hub.emit(Event{Type: EventOrderDispatched})
+	}
+	hub.flush(context.Background()) // does not block on the full channel
+	s.Equal(3, subscription.Dropped())
+	s.EqualValues(1, (<-subscription.Events).Sequence)
+	s.EqualValues(2, (<-subscription.Events).Sequence)
+
+	subscription.Unsubscribe()
+	subscription.Unsubscribe() // only closes once
+	_, ok := <-subscription.Events
+	s.False(ok)
+	hub.emit(Event{Type: EventOrderDispatched})
+	s.NotPanics(func() { hub.flush(context.Background()) })
+}
+
+func (s *SubscriptionTestSuite) TestSlowListener() {
+	hub := getEventHub(clock.GetRealClock())
+	release := make(chan struct{})
+	hub.subscribe(func(event Event) {
+		<-release
+	})
+	hub.emit(Event{Type: EventOrderDispatched})
+	go hub.deliver() // held up by the listener
+	emitted := make(chan struct{})
+	go func() {
+		for i := 0; i < 3; i++ {
+			hub.emit(Event{Type: EventOrderDispatched}) // never waits on the listener
+		}
+		close(emitted)
+	}()
+	select {
+	case <-emitted:
+	case <-time.After(time.Second):
+		s.Fail("the listener holds up the events")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	hub.flush(ctx) // gives up on the listener
+	s.Error(ctx.Err())
+	close(release)
+	hub.flush(context.Background())
+}
+
+func (s *SubscriptionTestSuite) TestSynchronousListener() {
+	manager := NewOrderManager(
+		getScriptedRand(gomock.NewController(s.T()), travelTimeValue(3)),
+		clock.GetRealClock(),
+		Config{Logger: &recordingEventLogger{}},
+		getMatchedStrategy(),
+	)
+	var received []Event
+	manager.Subscribe(func(event Event) {
+		received = append(received, event)
+	})
+	s.NoError(manager.DispatchOrder(context.Background(), &resource.Order{ID: "1", Name: "Food", PrepTime: 0}))
+	s.Require().NotEmpty(received) // by the time DispatchOrder returns
+	s.Equal(EventOrderDispatched, received[0].Type)
+	s.NoError(manager.Wait(context.Background()))
+}
+
+func (s *SubscriptionTestSuite) TestBlockedChannel() {
+	manager := NewOrderManager(
+		getScriptedRand(gomock.NewController(s.T()), travelTimeValue(3)),
+		clock.GetRealClock(),
+		Config{Logger: &recordingEventLogger{}, KeepRecords: true},
+		getMatchedStrategy(),
+	)
+	subscription := manager.SubscribeChannel(1) // never received from
+	start := time.Now()
+	s.NoError(manager.DispatchOrder(context.Background(), &resource.Order{ID: "1", Name: "Food", PrepTime: 1}))
+	s.Less(time.Since(start).Milliseconds(), int64(100)) // does not wait on the subscriber
+
+	s.NoError(manager.Wait(context.Background()))
+	records := manager.GetRecords()
+	s.Require().Len(records, 1)
+	s.InDelta(time.Second, records[0].ReadyAt.Sub(records[0].DispatchedAt), float64(100*time.Millisecond))
+	s.InDelta(3*time.Second, records[0].PickedUpAt.Sub(records[0].DispatchedAt), float64(100*time.Millisecond))
+	s.InDelta(2000, records[0].FoodWaitTime, 100)
+	s.Len(subscription.Events, 1)
+	s.Positive(subscription.Dropped())
+	subscription.Unsubscribe()
+}
+
+func (s *SubscriptionTestSuite) TestOrderManagerSubscriptions() {
+	var received []Event
+	var subscription *ChannelSubscription
+	runOnEngines(&s.Suite, engineRun{
+		strategy: FIFOStrategyName,
+		before: func(_ string, manager OrderManager) {
+			received = nil
+			manager.Subscribe(func(event Event) {
+				received = append(received, event)
+			})
+			subscription = manager.SubscribeChannel(100)
+		},
+	}, func(name string, _ OrderManager, _ *recordingEventLogger) {
+		s.Len(received, 24, name) // every event has been delivered by the time Wait returns
+		for i, event := range received {
+			s.EqualValues(i+1, event.Sequence, name)
+		}
+		s.Len(subscription.Events, 24, name)
+		s.Equal(0, subscription.Dropped(), name)
+		subscription.Unsubscribe()
+	})
+}
+
+func TestSubscriptionTestSuite(t *testing.T) {
+	suite.Run(t, new(SubscriptionTestSuite))
+}
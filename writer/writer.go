@@ -0,0 +1,132 @@
+package writer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"wonsoh.private/cloudkitchens/resource"
+)
+
+// Format is the format of a file of order records
+type Format string
+
+const (
+	// FormatCSV writes the records as CSV, with a header row
+	FormatCSV Format = "csv"
+	// FormatJSON writes the records as a JSON array
+	FormatJSON Format = "json"
+)
+
+// csvHeader is the header row of the CSV format, in the order of the columns
+var csvHeader = []string{
+	"order_id",
+	"name",
+	"prep_time",
+	"courier_id",
+	"travel_time",
+	"dispatched_at",
+	"ready_at",
+	"arrived_at",
+	"picked_up_at",
+	"food_wait_time_ms",
+	"courier_wait_time_ms",
+}
+
+// RecordWriter is a writer that writes the records of picked up orders
+type RecordWriter interface {
+	WriteRecords(records []resource.OrderRecord) error
+}
+
+type csvRecordWriter struct {
+	w io.Writer
+}
+
+type jsonRecordWriter struct {
+	w io.Writer
+}
+
+func formatTime(t time.Time) string {
+	return t.Format(time.RFC3339Nano)
+}
+
+func (c *csvRecordWriter) WriteRecords(records []resource.OrderRecord) error {
+	w := csv.NewWriter(c.w)
+	if err := w.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, record := range records {
+		if err := w.Write([]string{
+			record.OrderID,
+			record.Name,
+			strconv.Itoa(record.PrepTime),
+			record.CourierID,
+			strconv.Itoa(record.TravelTime),
+			formatTime(record.DispatchedAt),
+			formatTime(record.ReadyAt),
+			formatTime(record.ArrivedAt),
+			formatTime(record.PickedUpAt),
+			strconv.Itoa(record.FoodWaitTime),
+			strconv.Itoa(record.CourierWaitTime),
+		}); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func (j *jsonRecordWriter) WriteRecords(records []resource.OrderRecord) error {
+	if records == nil {
+		records = []resource.OrderRecord{} // an empty array rather than null
+	}
+	encoder := json.NewEncoder(j.w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(records)
+}
+
+// GetFormat gets the format of a file from its extension (.csv or .json)
+func GetFormat(path string) (Format, error) {
+	switch format := Format(strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))); format {
+	case FormatCSV, FormatJSON:
+		return format, nil
+	}
+	return "", fmt.Errorf("unknown format of %q; expected a .csv or .json file", path)
+}
+
+// GetRecordWriter constructs a new RecordWriter instance that writes to w in
+// the given format
+func GetRecordWriter(w io.Writer, format Format) (RecordWriter, error) {
+	switch format {
+	case FormatCSV:
+		return &csvRecordWriter{w: w}, nil
+	case FormatJSON:
+		return &jsonRecordWriter{w: w}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+// WriteRecordsFile writes the records to the file at path, in the format
+// given by its extension
+func WriteRecordsFile(path string, records []resource.OrderRecord) error {
+	format, err := GetFormat(path)
+	if err != nil {
+		return err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	w, _ := GetRecordWriter(file, format)
+	if err := w.WriteRecords(records); err != nil {
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,90 @@
+package writer
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"wonsoh.private/cloudkitchens/resource"
+)
+
+type WriterTestSuite struct {
+	suite.Suite
+	records []resource.OrderRecord
+}
+
+func (w *WriterTestSuite) SetupTest() {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	w.records = []resource.OrderRecord{
+		{
+			OrderID:         "1",
+			Name:            "Banana Split, Large",
+			PrepTime:        2,
+			CourierID:       "c1",
+			TravelTime:      4,
+			DispatchedAt:    start,
+			ReadyAt:         start.Add(2 * time.Second),
+			ArrivedAt:       start.Add(4 * time.Second),
+			PickedUpAt:      start.Add(4 * time.Second),
+			FoodWaitTime:    2000,
+			CourierWaitTime: 0,
+		},
+	}
+}
+
+func (w *WriterTestSuite) TestGetFormat() {
+	format, err := GetFormat("out/results.CSV")
+	w.NoError(err)
+	w.Equal(FormatCSV, format)
+	format, err = GetFormat("results.json")
+	w.NoError(err)
+	w.Equal(FormatJSON, format)
+	_, err = GetFormat("results.txt")
+	w.Error(err)
+	_, err = GetRecordWriter(&bytes.Buffer{}, "txt")
+	w.Error(err)
+}
+
+func (w *WriterTestSuite) TestCSV() {
+	buffer := &bytes.Buffer{}
+	writer, err := GetRecordWriter(buffer, FormatCSV)
+	w.NoError(err)
+	w.NoError(writer.WriteRecords(w.records))
+	w.Equal(
+		strings.Join(csvHeader, ",")+"\n"+
+			`1,"Banana Split, Large",2,c1,4,2021-01-01T00:00:00Z,2021-01-01T00:00:02Z,2021-01-01T00:00:04Z,2021-01-01T00:00:04Z,2000,0`+"\n",
+		buffer.String(),
+	)
+}
+
+func (w *WriterTestSuite) TestJSON() {
+	buffer := &bytes.Buffer{}
+	writer, err := GetRecordWriter(buffer, FormatJSON)
+	w.NoError(err)
+	w.NoError(writer.WriteRecords(w.records))
+	var records []resource.OrderRecord
+	w.NoError(json.Unmarshal(buffer.Bytes(), &records))
+	w.Equal(w.records, records)
+
+	buffer.Reset()
+	w.NoError(writer.WriteRecords(nil))
+	w.Equal("[]\n", buffer.String())
+}
+
+func (w *WriterTestSuite) TestWriteRecordsFile() {
+	path := filepath.Join(w.T().TempDir(), "results.csv")
+	w.NoError(WriteRecordsFile(path, w.records))
+	content, err := os.ReadFile(path)
+	w.NoError(err)
+	w.Len(strings.Split(strings.TrimSpace(string(content)), "\n"), 2)
+	w.Error(WriteRecordsFile(filepath.Join(w.T().TempDir(), "results.txt"), w.records))
+}
+
+func TestWriterTestSuite(t *testing.T) {
+	suite.Run(t, new(WriterTestSuite))
+}